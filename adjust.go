@@ -0,0 +1,35 @@
+package png2svg
+
+import "math"
+
+// Adjust changes the brightness, contrast and gamma of r, g and b, in that order, like in an image editor.
+// The brightness, from -1 to 1, is added to each channel, where 1 is 255. The contrast, from -1 to 1,
+// stretches each channel away from the middle, or towards it if it is negative, where -1 gives flat gray and
+// 1 gives only the darkest and lightest values. A gamma above 1 makes the dark parts lighter, and a gamma below 1
+// makes them darker. A brightness and contrast of 0 and a gamma of 0 or 1 leaves the colors as they are.
+// The alpha values are left as they are. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) Adjust(brightness, contrast, gamma float64) {
+	if gamma == 0 {
+		gamma = 1
+	}
+	if brightness == 0 && contrast == 0 && gamma == 1 {
+		return
+	}
+	factor := (1 + contrast) / (1 - contrast)
+	var table [256]int
+	for i := range table {
+		v := float64(i)/255 + brightness
+		v = (v-0.5)*factor + 0.5
+		// The value is clamped before the gamma, since negative numbers have no real roots.
+		// With the highest contrast, the middle value is 0 times infinity, which is not a number.
+		if v < 0 || math.IsNaN(v) {
+			v = 0
+		} else if v > 1 {
+			v = 1
+		}
+		table[i] = int(math.Pow(v, 1/gamma)*255 + 0.5)
+	}
+	for _, p := range pi.pixels {
+		p.r, p.g, p.b = table[p.r], table[p.g], table[p.b]
+	}
+}
@@ -0,0 +1,189 @@
+package png2svg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Animation contains the complete frames of an animated image,
+// how long each frame should be shown and how many times the animation should loop.
+type Animation struct {
+	Frames []image.Image
+	Delays []time.Duration
+	// LoopCount is 0 for looping forever, -1 for playing once
+	// and N for playing N+1 times, like for image/gif.
+	LoopCount int
+}
+
+// ReadGIFFrames reads all frames of the given GIF image filename.
+// Each frame is drawn on top of the previous ones, according to the disposal
+// method of the previous frame, so that all returned frames are complete images.
+// If verbose is true, some basic information is printed to stdout.
+func ReadGIFFrames(filename string, verbose bool) (*Animation, error) {
+	if verbose {
+		fmt.Printf("Reading %s", filename)
+		defer fmt.Println()
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("the GIF image has no frames")
+	}
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewNRGBA(bounds)
+	a := &Animation{LoopCount: g.LoopCount}
+	for i, frame := range g.Image {
+		var previous *image.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		complete := image.NewNRGBA(bounds)
+		draw.Draw(complete, bounds, canvas, image.Point{}, draw.Src)
+		a.Frames = append(a.Frames, complete)
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		a.Delays = append(a.Delays, time.Duration(delay)*10*time.Millisecond)
+
+		// Prepare the canvas for the next frame
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	if verbose {
+		fmt.Printf(" (%dx%d, %d frames)", g.Config.Width, g.Config.Height, len(a.Frames))
+	}
+	return a, nil
+}
+
+// svgContents returns the contents of the <svg> tag in the given SVG document,
+// together with everything up to and including the opening <svg> tag.
+// An empty <svg/> tag, like for a transparent image, is returned as an opening tag with no contents.
+func svgContents(svgDocument []byte) (head, contents []byte, err error) {
+	start := bytes.Index(svgDocument, []byte("<svg"))
+	if start == -1 {
+		return nil, nil, errors.New("not an SVG document")
+	}
+	tagEnd := bytes.IndexByte(svgDocument[start:], '>')
+	if tagEnd == -1 {
+		return nil, nil, errors.New("not an SVG document")
+	}
+	pos := start + tagEnd + 1
+	if svgDocument[pos-2] == '/' {
+		head = append(append([]byte{}, svgDocument[:pos-2]...), '>')
+		return head, nil, nil
+	}
+	end := bytes.LastIndex(svgDocument, []byte("</svg>"))
+	if end < pos {
+		return nil, nil, errors.New("not an SVG document")
+	}
+	return svgDocument[:pos], svgDocument[pos:end], nil
+}
+
+var (
+	// idPattern matches the ids and classes of elements, and the references to ids
+	idPattern = regexp.MustCompile(`( id="| class="|href="#|url\(#)([^"')]+)`)
+	// stylePattern matches a <style> block, where classSelectorPattern matches the class selectors
+	stylePattern         = regexp.MustCompile(`<style>[^<]*</style>`)
+	classSelectorPattern = regexp.MustCompile(`\.([A-Za-z_][\w-]*)\{`)
+)
+
+// prefixIDs adds the given prefix to all ids, classes and references to ids in the given
+// SVG markup, like the checkerboard pattern, the <use> definitions and the layers, so that
+// the markup of several images can be placed in one SVG document without clashing ids.
+func prefixIDs(markup []byte, prefix string) []byte {
+	markup = idPattern.ReplaceAll(markup, []byte("${1}"+prefix+"${2}"))
+	return stylePattern.ReplaceAllFunc(markup, func(style []byte) []byte {
+		return classSelectorPattern.ReplaceAll(style, []byte("."+prefix+"${1}{"))
+	})
+}
+
+// AnimatedSVG combines the given SVG documents, one per frame, into a single
+// SVG document where each frame is shown in turn, by using SMIL animation.
+// The XML prolog and the <svg> tag are taken from the first document.
+// The ids and classes of each frame are prefixed with the frame number, like "f3-r0", so that they are unique.
+// Frames with a delay of 0 are shown for 100ms, like most browsers do for GIF images.
+func (a *Animation) AnimatedSVG(documents [][]byte) ([]byte, error) {
+	if len(documents) == 0 || len(documents) != len(a.Delays) {
+		return nil, errors.New("there must be one SVG document per frame")
+	}
+	delays := make([]time.Duration, len(a.Delays))
+	var total time.Duration
+	for i, delay := range a.Delays {
+		if delay <= 0 {
+			delay = 100 * time.Millisecond
+		}
+		delays[i] = delay
+		total += delay
+	}
+	repeatCount := "indefinite"
+	if a.LoopCount != 0 {
+		count := a.LoopCount + 1
+		if a.LoopCount < 0 {
+			count = 1
+		}
+		repeatCount = strconv.Itoa(count)
+	}
+	keyTime := func(t time.Duration) string {
+		return strconv.FormatFloat(float64(t)/float64(total), 'f', -1, 64)
+	}
+
+	var (
+		buf   bytes.Buffer
+		start time.Duration
+	)
+	for i, document := range documents {
+		head, contents, err := svgContents(document)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			buf.Write(head)
+		}
+		end := start + delays[i]
+		values, keyTimes := "visible", "0"
+		if start > 0 {
+			values, keyTimes = "hidden;visible", "0;"+keyTime(start)
+		}
+		if end < total {
+			values += ";hidden"
+			keyTimes += ";" + keyTime(end)
+		}
+		visibility := "hidden"
+		if start == 0 {
+			visibility = "visible"
+		}
+		buf.WriteString(`<g visibility="` + visibility + `">`)
+		buf.WriteString(`<animate attributeName="visibility" values="` + values + `" keyTimes="` + keyTimes + `" dur="` + strconv.FormatFloat(total.Seconds(), 'f', -1, 64) + `s" calcMode="discrete" repeatCount="` + repeatCount + `" fill="freeze"/>`)
+		buf.Write(prefixIDs(contents, "f"+strconv.Itoa(i)+"-"))
+		buf.WriteString("</g>")
+		start = end
+	}
+	buf.WriteString("</svg>")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,238 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"time"
+)
+
+// APNG dispose and blend operations, from the fcTL chunk
+const (
+	apngDisposeBackground = 1
+	apngDisposePrevious   = 2
+	apngBlendOver         = 1
+)
+
+// apngFrame is a frame control chunk (fcTL), together with the image data of the frame
+type apngFrame struct {
+	width, height    int
+	x, y             int
+	delay            time.Duration
+	dispose, blend   byte
+	imageData        []byte
+	isDefaultPicture bool
+}
+
+// isAPNG checks if the given PNG data is an animated PNG with more than one frame
+func isAPNG(data []byte) bool {
+	for _, chunk := range pngChunks(data) {
+		if chunk.name == "IDAT" {
+			// acTL must come before the image data
+			return false
+		}
+		if chunk.name == "acTL" && len(chunk.data) >= 8 {
+			return binary.BigEndian.Uint32(chunk.data) > 1
+		}
+	}
+	return false
+}
+
+// IsAnimated checks if the given image filename is an animated GIF or PNG image,
+// with more than one frame
+func IsAnimated(filename string) (bool, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false, err
+	}
+	if bytes.HasPrefix(data, pngSignature) {
+		return isAPNG(data), nil
+	}
+	if bytes.HasPrefix(data, []byte("GIF8")) {
+		a, err := ReadGIFFrames(filename, false)
+		if err != nil {
+			return false, err
+		}
+		return len(a.Frames) > 1, nil
+	}
+	return false, nil
+}
+
+// ReadFrames reads all frames of the given animated GIF or PNG image filename.
+// See ReadGIFFrames and ReadAPNGFrames.
+func ReadFrames(filename string, verbose bool) (*Animation, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, pngSignature) {
+		return ReadAPNGFrames(filename, verbose)
+	}
+	return ReadGIFFrames(filename, verbose)
+}
+
+// ReadAPNGFrames reads all frames of the given animated PNG image filename.
+// Each frame is drawn on top of the previous ones, according to the dispose and
+// blend operations, so that all returned frames are complete images.
+// A PNG image that is not animated is returned as a single frame.
+// If verbose is true, some basic information is printed to stdout.
+func ReadAPNGFrames(filename string, verbose bool) (*Animation, error) {
+	if verbose {
+		fmt.Printf("Reading %s", filename)
+		defer fmt.Println()
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	chunks := pngChunks(data)
+	if len(chunks) == 0 || chunks[0].name != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New(filename + " is not a PNG image")
+	}
+	ihdr := chunks[0].data
+	width := int(binary.BigEndian.Uint32(ihdr[0:]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+
+	var (
+		a        = &Animation{}
+		frames   []*apngFrame
+		current  *apngFrame
+		shared   [][]byte // chunks like PLTE and tRNS, that are needed for decoding every frame
+		seenIDAT bool
+	)
+	for _, chunk := range chunks[1:] {
+		switch chunk.name {
+		case "acTL":
+			if len(chunk.data) < 8 {
+				return nil, errors.New("invalid acTL chunk")
+			}
+			// The number of plays is 0 for looping forever
+			plays := int(binary.BigEndian.Uint32(chunk.data[4:]))
+			switch plays {
+			case 0:
+				a.LoopCount = 0
+			case 1:
+				a.LoopCount = -1
+			default:
+				a.LoopCount = plays - 1
+			}
+		case "fcTL":
+			if len(chunk.data) < 26 {
+				return nil, errors.New("invalid fcTL chunk")
+			}
+			d := chunk.data
+			delayDen := binary.BigEndian.Uint16(d[22:])
+			if delayDen == 0 {
+				delayDen = 100
+			}
+			delayNum := binary.BigEndian.Uint16(d[20:])
+			current = &apngFrame{
+				width:            int(binary.BigEndian.Uint32(d[4:])),
+				height:           int(binary.BigEndian.Uint32(d[8:])),
+				x:                int(binary.BigEndian.Uint32(d[12:])),
+				y:                int(binary.BigEndian.Uint32(d[16:])),
+				delay:            time.Duration(delayNum) * time.Second / time.Duration(delayDen),
+				dispose:          d[24],
+				blend:            d[25],
+				isDefaultPicture: !seenIDAT,
+			}
+			frames = append(frames, current)
+		case "IDAT":
+			seenIDAT = true
+			if current != nil && current.isDefaultPicture {
+				current.imageData = append(current.imageData, chunk.data...)
+			}
+		case "fdAT":
+			if current != nil && len(chunk.data) >= 4 {
+				current.imageData = append(current.imageData, chunk.data[4:]...)
+			}
+		case "IEND":
+		default:
+			if !seenIDAT {
+				shared = append(shared, pngChunkBytes(chunk.name, chunk.data))
+			}
+		}
+	}
+	if len(frames) == 0 {
+		// Not animated, use the default image
+		img, err := decodePNG(data, verbose)
+		if err != nil {
+			return nil, err
+		}
+		a.Frames = []image.Image{img}
+		a.Delays = []time.Duration{0}
+		return a, nil
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+	canvas := image.NewNRGBA(bounds)
+	for _, frame := range frames {
+		img, err := decodeAPNGFrame(ihdr, shared, frame)
+		if err != nil {
+			return nil, err
+		}
+		var previous *image.NRGBA
+		if frame.dispose == apngDisposePrevious {
+			previous = image.NewNRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+		r := image.Rect(frame.x, frame.y, frame.x+frame.width, frame.y+frame.height)
+		op := draw.Src
+		if frame.blend == apngBlendOver {
+			op = draw.Over
+		}
+		draw.Draw(canvas, r, img, img.Bounds().Min, op)
+
+		complete := image.NewNRGBA(bounds)
+		draw.Draw(complete, bounds, canvas, image.Point{}, draw.Src)
+		a.Frames = append(a.Frames, complete)
+		a.Delays = append(a.Delays, frame.delay)
+
+		// Prepare the canvas for the next frame
+		switch frame.dispose {
+		case apngDisposeBackground:
+			draw.Draw(canvas, r, image.Transparent, image.Point{}, draw.Src)
+		case apngDisposePrevious:
+			canvas = previous
+		}
+	}
+	if verbose {
+		fmt.Printf(" (%dx%d, %d frames)", width, height, len(a.Frames))
+	}
+	return a, nil
+}
+
+// decodeAPNGFrame decodes a single APNG frame, by creating a regular PNG image
+// with the size of the frame, the shared chunks and the image data of the frame
+func decodeAPNGFrame(ihdr []byte, shared [][]byte, frame *apngFrame) (image.Image, error) {
+	header := make([]byte, len(ihdr))
+	copy(header, ihdr)
+	binary.BigEndian.PutUint32(header[0:], uint32(frame.width))
+	binary.BigEndian.PutUint32(header[4:], uint32(frame.height))
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	buf.Write(pngChunkBytes("IHDR", header))
+	for _, chunk := range shared {
+		buf.Write(chunk)
+	}
+	buf.Write(pngChunkBytes("IDAT", frame.imageData))
+	buf.Write(pngChunkBytes("IEND", nil))
+	return png.Decode(&buf)
+}
+
+// pngChunkBytes returns a complete PNG chunk, with length, type, data and CRC
+func pngChunkBytes(name string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], name)
+	chunk = append(chunk, data...)
+	crc := crc32.ChecksumIEEE(chunk[4:])
+	return append(chunk, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
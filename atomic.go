@@ -0,0 +1,71 @@
+package png2svg
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// CreateTemp creates a temporary file in the same directory as the given filename, so that it can
+// be renamed into place by CommitTemp when it has been completely written. The directory is created
+// if it does not exist, where the directory is searchable by those who can read the file, like 0755
+// for 0644. If mode is 0, the directory gets the default permissions, as limited by the umask.
+func CreateTemp(filename string, mode os.FileMode) (*os.File, error) {
+	dir, base := filepath.Dir(filename), filepath.Base(filename)
+	dirPerm := os.FileMode(0777)
+	if mode != 0 {
+		dirPerm = mode | (mode&0444)>>2
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, err
+	}
+	for tries := 0; ; tries++ {
+		// The file is created with 0666, like by os.Create, so that the umask applies to it
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && tries < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// CommitTemp closes the given temporary file from CreateTemp, sets the permissions and renames it to the
+// given filename. If mode is 0, an existing file keeps its permissions, and a new file gets the default
+// permissions, as limited by the umask. If err is not nil, or if anything fails, the temporary file is
+// removed instead, so that a truncated output file is never left behind. The first error is returned.
+func CommitTemp(f *os.File, filename string, mode os.FileMode, err error) error {
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && mode == 0 {
+		if fi, statErr := os.Stat(filename); statErr == nil {
+			mode = fi.Mode().Perm()
+		}
+	}
+	if err == nil && mode != 0 {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// WriteFileAtomic writes the given data to a temporary file, and then renames it to the given filename,
+// so that an interrupted write never leaves a truncated file behind. See CommitTemp for the permissions.
+func WriteFileAtomic(filename string, data []byte, mode os.FileMode) error {
+	f, err := CreateTemp(filename, mode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	return CommitTemp(f, filename, mode, err)
+}
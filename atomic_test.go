@@ -0,0 +1,74 @@
+package png2svg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWriteFileAtomicMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions work differently on Windows")
+	}
+	dir, err := ioutil.TempDir("", "png2svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A file that is created like by os.Create has the default permissions, as limited by the umask
+	reference := filepath.Join(dir, "reference")
+	f, err := os.OpenFile(reference, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	fi, err := os.Stat(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	umasked := fi.Mode().Perm()
+
+	existing := filepath.Join(dir, "existing.svg")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(existing, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		mode     os.FileMode
+		expected os.FileMode
+	}{
+		{filepath.Join(dir, "new.svg"), 0, umasked},
+		{existing, 0, 0600},
+		{filepath.Join(dir, "explicit.svg"), 0640, 0640},
+		{existing, 0604, 0604},
+		{filepath.Join(dir, "sub", "dir", "nested.svg"), 0, umasked},
+	}
+	for _, test := range tests {
+		if err := WriteFileAtomic(test.filename, []byte("<svg/>"), test.mode); err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+		fi, err := os.Stat(test.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := fi.Mode().Perm(); perm != test.expected {
+			t.Errorf("%s with mode %o: expected the permissions %o, got %o", test.filename, test.mode, test.expected, perm)
+		}
+		if data, _ := ioutil.ReadFile(test.filename); string(data) != "<svg/>" {
+			t.Errorf("%s: expected the new contents, got %q", test.filename, data)
+		}
+	}
+
+	// No temporary files are left behind
+	matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if len(matches) > 0 {
+		t.Errorf("temporary files were left behind: %v", matches)
+	}
+}
@@ -0,0 +1,137 @@
+package png2svg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CoverDominantColor draws one rectangle with the most frequent color across the whole image,
+// and marks the pixels with that color as covered, so that no other rectangles are needed for them.
+// For icons and sprites with large flat backgrounds, this removes most of the rectangles.
+// This is only done if the image has no transparency, since the rectangle covers all pixels.
+// Returns true if the rectangle was drawn.
+func (pi *PixelImage) CoverDominantColor() bool {
+	if len(pi.pixels) == 0 || pi.HasTransparency() {
+		return false
+	}
+
+	// Count the colors, and find the most frequent one. Ties go to the color that appears first.
+	var (
+		counts = make(map[[3]int]int)
+		best   [3]int
+		max    int
+	)
+	for _, p := range pi.pixels {
+		c := [3]int{p.r, p.g, p.b}
+		counts[c]++
+		if counts[c] > max {
+			best, max = c, counts[c]
+		}
+	}
+
+	colorString := string(hexColorBytes(best[0], best[1], best[2]))
+	if pi.colorOptimize {
+		colorString = shortColorString(best[0], best[1], best[2])
+	}
+	pi.addRect(0, 0, pi.w, pi.h, colorString, 255)
+	for _, p := range pi.pixels {
+		if p.r == best[0] && p.g == best[1] && p.b == best[2] {
+			p.covered = true
+		}
+	}
+	return true
+}
+
+// ParseHexColor parses a color on the form "#rrggbb" or "#rgb" (the "#" is optional)
+// and returns the 8-bit red, green and blue values
+func ParseHexColor(s string) (int, int, int, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid color: %q (expected #rrggbb or #rgb)", s)
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), nil
+}
+
+// CompositeOver blends all pixels that are not fully opaque with the given background color,
+// so that the SVG image has no transparency. This is for renderers and plotters that can not
+// handle opacity. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) CompositeOver(r, g, b int) {
+	for _, p := range pi.pixels {
+		if p.a == 255 {
+			continue
+		}
+		p.r = (p.r*p.a + r*(255-p.a) + 127) / 255
+		p.g = (p.g*p.a + g*(255-p.a) + 127) / 255
+		p.b = (p.b*p.a + b*(255-p.a) + 127) / 255
+		p.a = 255
+		p.covered = false
+	}
+}
+
+// transparentPixels returns which of the pixels are fully transparent, in the same order as the pixels
+func (pi *PixelImage) transparentPixels() []bool {
+	transparent := make([]bool, len(pi.pixels))
+	for i, p := range pi.pixels {
+		transparent[i] = p.a == 0
+	}
+	return transparent
+}
+
+// clearPixels makes the pixels that are true in the given list, from transparentPixels,
+// fully transparent again, after the colors have been changed
+func (pi *PixelImage) clearPixels(transparent []bool) {
+	for i, p := range pi.pixels {
+		if i < len(transparent) && transparent[i] {
+			p.r, p.g, p.b, p.a = 0, 0, 0, 0
+			p.covered = true
+		}
+	}
+}
+
+// ClearColorKey makes the pixels that have the given color fully transparent, so that no rectangles
+// are drawn for them. This is for sprites that use a key color, like magenta, instead of an alpha channel.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) ClearColorKey(r, g, b int) {
+	for _, p := range pi.pixels {
+		if p.r == r && p.g == g && p.b == b {
+			p.a = 0
+			p.covered = true
+		}
+	}
+}
+
+// MapColors gives the pixels that have one of the colors in the mapping, as r, g and b, the color that
+// it is mapped to, like for swapping the team colors of a sprite. The alpha values are left as they are.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) MapColors(mapping map[[3]int][3]int) {
+	if len(mapping) == 0 {
+		return
+	}
+	for _, p := range pi.pixels {
+		if c, ok := mapping[[3]int{p.r, p.g, p.b}]; ok {
+			p.r, p.g, p.b = c[0], c[1], c[2]
+		}
+	}
+}
+
+// parseColorMap parses a mapping from colors to colors, like "#f00" to "#00f", into r, g and b values
+func parseColorMap(colorMap map[string]string) (map[[3]int][3]int, error) {
+	mapping := make(map[[3]int][3]int, len(colorMap))
+	for from, to := range colorMap {
+		fr, fg, fb, err := ParseHexColor(from)
+		if err != nil {
+			return nil, err
+		}
+		tr, tg, tb, err := ParseHexColor(to)
+		if err != nil {
+			return nil, err
+		}
+		mapping[[3]int{fr, fg, fb}] = [3]int{tr, tg, tb}
+	}
+	return mapping, nil
+}
@@ -0,0 +1,74 @@
+package png2svg
+
+// AverageBlocks gives all pixels in each n×n block the average color of the block, so that the
+// image can be covered with fewer rectangles. The colors are weighted by the alpha values, so that
+// transparent pixels do not darken the block. Blocks along the right and bottom edges may be smaller.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) AverageBlocks(n int) {
+	pi.eachBlock(n, func(block []*Pixel) {
+		var r, g, b, a int
+		for _, p := range block {
+			r += p.r * p.a
+			g += p.g * p.a
+			b += p.b * p.a
+			a += p.a
+		}
+		if a == 0 {
+			r, g, b = 0, 0, 0
+		} else {
+			r, g, b = (r+a/2)/a, (g+a/2)/a, (b+a/2)/a
+		}
+		a = (a + len(block)/2) / len(block)
+		for _, p := range block {
+			p.r, p.g, p.b, p.a = r, g, b, a
+			p.covered = a == 0
+		}
+	})
+}
+
+// MajorityBlocks gives all pixels in each n×n block the most frequent color of the block.
+// If several colors are just as frequent, the first one from the top left is used.
+// Unlike AverageBlocks, no new colors are made. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) MajorityBlocks(n int) {
+	counts := make(map[[4]int]int)
+	pi.eachBlock(n, func(block []*Pixel) {
+		for k := range counts {
+			delete(counts, k)
+		}
+		for _, p := range block {
+			counts[[4]int{p.r, p.g, p.b, p.a}]++
+		}
+		var (
+			most      [4]int
+			mostCount int
+		)
+		for _, p := range block {
+			if c := [4]int{p.r, p.g, p.b, p.a}; counts[c] > mostCount {
+				most, mostCount = c, counts[c]
+			}
+		}
+		for _, p := range block {
+			p.r, p.g, p.b, p.a = most[0], most[1], most[2], most[3]
+			p.covered = p.a == 0
+		}
+	})
+}
+
+// eachBlock calls the given function with the pixels of each n×n block, row by row
+func (pi *PixelImage) eachBlock(n int, f func(block []*Pixel)) {
+	if n < 2 {
+		return
+	}
+	block := make([]*Pixel, 0, n*n)
+	for by := 0; by < pi.h; by += n {
+		for bx := 0; bx < pi.w; bx += n {
+			block = block[:0]
+			for y := by; y < by+n && y < pi.h; y++ {
+				for x := bx; x < bx+n && x < pi.w; x++ {
+					block = append(block, pi.pixels[y*pi.w+x])
+				}
+			}
+			f(block)
+		}
+	}
+}
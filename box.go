@@ -1,11 +1,10 @@
 package png2svg
 
 import (
+	"errors"
 	"fmt"
-	"math/rand"
 	"strconv"
-
-	"github.com/xyproto/tinysvg"
+	"strings"
 )
 
 // Box represents a box with the following properties:
@@ -18,6 +17,70 @@ type Box struct {
 	r, g, b, a int
 }
 
+// Rect is a rectangle that has been placed in the SVG image,
+// with a fill color on the form "#rrggbb" or "#rgb" (or "#rrggbbaa" or "#rgba", if hex alpha is enabled)
+// and an alpha value from 0 to 255
+type Rect struct {
+	X, Y  int
+	W, H  int
+	Fill  string
+	Alpha int
+}
+
+// RGB returns the fill color of the rectangle as 8-bit red, green and blue values
+func (r Rect) RGB() (int, int, int) {
+	s := strings.TrimPrefix(r.Fill, "#")
+	if len(s) == 4 || len(s) == 8 {
+		// Skip the alpha value
+		s = s[:len(s)/4*3]
+	}
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return 0, 0, 0
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+}
+
+// ExpandOrder decides in which direction a box prefers to grow when expanding
+type ExpandOrder int
+
+const (
+	// RightFirst expands a box to the right before trying to expand it downwards
+	RightFirst ExpandOrder = iota
+	// DownFirst expands a box downwards before trying to expand it to the right
+	DownFirst
+	// Balanced alternates, by expanding along the shortest side of the box first
+	Balanced
+)
+
+// ParseExpandOrder returns the ExpandOrder for the given name,
+// which can be "right", "down" or "balanced".
+func ParseExpandOrder(name string) (ExpandOrder, error) {
+	switch strings.ToLower(name) {
+	case "right", "rightfirst":
+		return RightFirst, nil
+	case "down", "downfirst":
+		return DownFirst, nil
+	case "balanced":
+		return Balanced, nil
+	}
+	return RightFirst, errors.New("unknown expand order: " + name + " (must be right, down or balanced)")
+}
+
+// String returns the name of the ExpandOrder
+func (order ExpandOrder) String() string {
+	switch order {
+	case DownFirst:
+		return "down"
+	case Balanced:
+		return "balanced"
+	}
+	return "right"
+}
+
 // CreateRandomBox randomly searches for a place for a 1x1 size box.
 // Note: If checkIfPossible is true, the function continue running until
 // it either finds a free spot or no spots are available.
@@ -27,8 +90,8 @@ func (pi *PixelImage) CreateRandomBox(checkIfPossible bool) *Box {
 	var x, y, r, g, b, a int
 	for !checkIfPossible || !pi.Done(0, 0) {
 		// Find a random placement for (x,y), for a box of size (1,1)
-		x = rand.Intn(pi.w)
-		y = rand.Intn(pi.h)
+		x = pi.rng.Intn(pi.w)
+		y = pi.rng.Intn(pi.h)
 		if pi.verbose {
 			fmt.Printf("Random box at (%d, %d)\n", x, y)
 		}
@@ -133,8 +196,20 @@ func (pi *PixelImage) ExpandDown(bo *Box) bool {
 	return true
 }
 
-// ExpandOnce tries to expand the box to the right and downwards, once
+// ExpandOnce tries to expand the box to the right and downwards, once.
+// The preferred direction is decided by the expand order of the PixelImage.
 func (pi *PixelImage) ExpandOnce(bo *Box) bool {
+	downFirst := pi.expandOrder == DownFirst
+	if pi.expandOrder == Balanced {
+		// Grow the box along the shortest side, to keep it close to a square
+		downFirst = bo.h < bo.w
+	}
+	if downFirst {
+		if pi.ExpandDown(bo) {
+			return true
+		}
+		return pi.ExpandRight(bo)
+	}
 	if pi.ExpandRight(bo) {
 		return true
 	}
@@ -168,13 +243,24 @@ func shortColorString(r, g, b int) string {
 	return "#" + singleHex(r) + singleHex(g) + singleHex(b)
 }
 
+// withAlpha appends the given alpha value (0 to 255) to the given color string,
+// with one hex digit for "#rgb" colors and two for "#rrggbb" colors
+func withAlpha(colorString string, alpha int) string {
+	if len(colorString) == len("#rgb") {
+		return colorString + singleHex(alpha)
+	}
+	return colorString + fmt.Sprintf("%02x", alpha)
+}
+
+// opacityString returns the given alpha value (0 to 255) as an opacity from 0 to 1, with at most 3 decimals
+func opacityString(alpha int) string {
+	return formatNumber(float64(alpha) / 255)
+}
+
 // CoverBox creates rectangles in the SVG image, and also marks the pixels as covered
 // if pink is true, the rectangles will be pink
 // if optimizeColors is true, the color strings will be shortened (and quantized)
 func (pi *PixelImage) CoverBox(bo *Box, pink bool, optimizeColors bool) {
-	// Draw the rectangle
-	rect := pi.svgTag.AddRect(bo.x, bo.y, bo.w, bo.h)
-
 	// Generate a fill color string
 	var colorString string
 	if pink {
@@ -186,11 +272,11 @@ func (pi *PixelImage) CoverBox(bo *Box, pink bool, optimizeColors bool) {
 	} else if optimizeColors {
 		colorString = shortColorString(bo.r, bo.g, bo.b)
 	} else {
-		colorString = string(tinysvg.ColorBytes(bo.r, bo.g, bo.b))
+		colorString = string(hexColorBytes(bo.r, bo.g, bo.b))
 	}
 
-	// Set the fill color
-	rect.Fill(colorString)
+	// Draw the rectangle
+	pi.addRect(bo.x, bo.y, bo.w, bo.h, colorString, bo.a)
 
 	// Mark all covered pixels in the PixelImage
 	for y := bo.y; y < (bo.y + bo.h); y++ {
@@ -0,0 +1,99 @@
+package png2svg
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"testing"
+)
+
+// fixtures are the images that the rectangle placement is tested and benchmarked with
+var fixtures = []string{
+	filepath.Join("img", "bonzomatic.png"),
+	filepath.Join("img", "glenda.png"),
+	filepath.Join("img", "rainforest.png"),
+	filepath.Join("img", "spaceships.png"),
+	filepath.Join("testdata", "jumpline16.png"),
+}
+
+var expandOrders = []ExpandOrder{RightFirst, DownFirst, Balanced}
+
+// checkCoverage checks that every pixel that is not fully transparent in the given image is covered
+// by the rectangles, and that all rectangles have the color of the pixels they cover.
+// The expanded boxes may overlap, since they can grow over pixels of the same color.
+func checkCoverage(t *testing.T, name string, img image.Image, rects []Rect) {
+	t.Helper()
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	count := make([]int, w*h)
+	for _, rect := range rects {
+		rr, rg, rb := rect.RGB()
+		for y := rect.Y; y < rect.Y+rect.H; y++ {
+			for x := rect.X; x < rect.X+rect.W; x++ {
+				if x < 0 || y < 0 || x >= w || y >= h {
+					t.Fatalf("%s: rectangle %v is outside of the image", name, rect)
+				}
+				count[y*w+x]++
+				r, g, b, a := unpremultiply(img.At(b.Min.X+x, b.Min.Y+y).RGBA())
+				if r != rr || g != rg || b != rb || a != rect.Alpha {
+					t.Fatalf("%s: rectangle %v has the wrong color for pixel (%d, %d)", name, rect, x, y)
+				}
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			_, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			if (a == 0) != (count[y*w+x] == 0) {
+				t.Fatalf("%s: pixel (%d, %d) with alpha %d is covered %d times", name, x, y, a>>8, count[y*w+x])
+			}
+		}
+	}
+}
+
+func TestExpandOrderCoverage(t *testing.T) {
+	for _, filename := range fixtures {
+		img := mustReadImage(t, filename)
+		for _, order := range expandOrders {
+			pi := NewPixelImage(img, false)
+			pi.SetExpandOrder(order)
+			pi.Cover(false, false)
+			checkCoverage(t, fmt.Sprintf("%s (%s)", filename, order), img, pi.Rectangles())
+		}
+	}
+}
+
+func TestParseExpandOrder(t *testing.T) {
+	for _, order := range expandOrders {
+		parsed, err := ParseExpandOrder(order.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != order {
+			t.Errorf("expected %s, got %s", order, parsed)
+		}
+	}
+	if _, err := ParseExpandOrder("diagonal"); err == nil {
+		t.Error("expected an error for an unknown expand order")
+	}
+}
+
+func BenchmarkExpandOrder(b *testing.B) {
+	for _, filename := range fixtures {
+		img, err := ReadImage(filename, true, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, order := range expandOrders {
+			b.Run(fmt.Sprintf("%s/%s", filepath.Base(filename), order), func(b *testing.B) {
+				pi := NewPixelImage(img, false)
+				pi.SetExpandOrder(order)
+				for i := 0; i < b.N; i++ {
+					pi.Reset(img)
+					pi.Cover(false, false)
+				}
+				b.ReportMetric(float64(pi.RectangleCount()), "rects")
+			})
+		}
+	}
+}
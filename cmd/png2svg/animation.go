@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/xyproto/png2svg"
+)
+
+// convertAnimation converts all frames of an animated GIF or PNG image and writes
+// a single animated SVG image to outputFilename
+func convertAnimation(c *Config, outputFilename string) error {
+	if outputFilename == "-" {
+		// Turn off verbose messages, so that they don't end up in the SVG output
+		c.opts.Verbose = false
+	}
+	a, err := png2svg.ReadFrames(c.inputFilename, c.opts.Verbose)
+	if err != nil {
+		return err
+	}
+	if len(a.Frames) == 0 {
+		return errors.New(c.inputFilename + " has no frames")
+	}
+	documents := make([][]byte, len(a.Frames))
+	for i, frame := range a.Frames {
+		documents[i] = convertImage(c, frame).Bytes()
+	}
+	data, err := a.AnimatedSVG(documents)
+	if err != nil {
+		return err
+	}
+	b := a.Frames[0].Bounds()
+	return c.writeData(expandImagePlaceholders(outputFilename, b.Dx(), b.Dy(), countFillColors(data)), data)
+}
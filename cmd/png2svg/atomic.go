@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// parseMode parses the given octal file permissions, like "0644"
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode: %s", s)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// comparison contains the results of converting an image with one set of flags
+type comparison struct {
+	flags  string
+	rects  int
+	colors int
+	data   []byte
+}
+
+// compare converts the input image twice, with the two sets of flags given
+// as "flagsA|flagsB", and prints a table with the number of rectangles,
+// the number of colors and the size of the resulting SVG images.
+// The best result is only written to file if an output filename was given.
+func compare(c *Config) error {
+	flagSets := strings.Split(c.compare, "|")
+	if len(flagSets) != 2 {
+		return errors.New("-compare needs two sets of flags, separated by |")
+	}
+
+	img, err := c.readImage(c.inputFilename)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*comparison, len(flagSets))
+	for i, flags := range flagSets {
+		flags = strings.TrimSpace(flags)
+		args := append(strings.Fields(flags), c.inputFilename)
+		fc, _, err := NewConfigFromArgs("compare", args, flag.ContinueOnError)
+		if err != nil {
+			return fmt.Errorf("%q: %s", flags, err)
+		}
+		fc.opts.Verbose = c.opts.Verbose
+		pi := convertImage(fc, img)
+		results[i] = &comparison{flags, pi.RectangleCount(), pi.ColorCount(), pi.Bytes()}
+	}
+
+	fmt.Printf("%-4s %-30s %10s %10s %10s\n", "", "flags", "rects", "colors", "bytes")
+	for i, r := range results {
+		fmt.Printf("%-4s %-30s %10d %10d %10d\n", string('A'+rune(i))+":", r.flags, r.rects, r.colors, len(r.data))
+	}
+
+	// The smallest SVG image wins. If the sizes are equal, the one with the fewest rectangles wins.
+	winner := 0
+	a, b := results[0], results[1]
+	if len(b.data) < len(a.data) || (len(b.data) == len(a.data) && b.rects < a.rects) {
+		winner = 1
+	}
+	fmt.Printf("Winner: %c (%s)\n", 'A'+rune(winner), results[winner].flags)
+
+	if c.outputFile != "" {
+		return c.writeData(c.outputPath("", c.inputFilename), results[winner].data)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// dataURI returns the given SVG image as a data URI, that can be used directly in CSS or HTML.
+// If urlEncode is true, the SVG image is URL encoded instead of base64 encoded, which is often shorter.
+func dataURI(svgData []byte, urlEncode bool) string {
+	if !urlEncode {
+		return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString(svgData)
+	}
+	var sb strings.Builder
+	sb.WriteString("data:image/svg+xml,")
+	for _, b := range svgData {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+			sb.WriteByte(b)
+		case strings.IndexByte("-._~:/?=;,'()*!@$&+", b) >= 0:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// isURL checks if the given input filename is a http:// or https:// URL
+func isURL(filename string) bool {
+	return strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// urlBase returns the last element of the path in the given URL, like "image.png",
+// or "index" if the URL has no path
+func urlBase(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+		return "index"
+	}
+	return path.Base(u.Path)
+}
+
+// download fetches the given URL, and returns the response body.
+// The timeout covers the entire download, including reading the body.
+func download(rawURL string, timeout time.Duration) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("could not download %s: %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// hasGlob checks if the given filename contains any glob pattern characters
+func hasGlob(filename string) bool {
+	return strings.ContainsAny(filename, "*?[")
+}
+
+// globRoot returns the leading directories of the given pattern that do not contain
+// any glob pattern characters, or "." if the pattern starts with a glob
+func globRoot(pattern string) string {
+	var root []string
+	for _, part := range strings.Split(pattern, "/") {
+		if hasGlob(part) {
+			break
+		}
+		root = append(root, part)
+	}
+	if len(root) == 0 {
+		return "."
+	}
+	if len(root) == 1 && root[0] == "" {
+		// The pattern starts with "/"
+		return "/"
+	}
+	return strings.Join(root, "/")
+}
+
+// matchParts checks if the given path elements match the given pattern elements,
+// where "**" matches zero or more directories
+func matchParts(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], parts[0]); err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// expandGlob returns the files that match the given glob pattern, in lexical order.
+// In addition to the patterns supported by filepath.Match, "**" matches zero or more directories.
+func expandGlob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, match := range matches {
+			if !isDir(match) {
+				files = append(files, filepath.ToSlash(match))
+			}
+		}
+		return files, nil
+	}
+	root := globRoot(pattern)
+	rest := strings.Split(strings.TrimPrefix(strings.TrimPrefix(pattern, root), "/"), "/")
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if matchParts(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			files = append(files, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// expandInputs replaces any glob patterns among c.inputFilenames with the matching files.
+// The directory that a glob pattern starts from is used as the base directory for the
+// output filenames, so that the matching files keep their relative location.
+func (c *Config) expandInputs() error {
+	var (
+		filenames []string
+		baseDirs  []string
+	)
+	for _, filename := range c.inputFilenames {
+		if !hasGlob(filename) || exists(filename) {
+			filenames = append(filenames, filename)
+			baseDirs = append(baseDirs, "")
+			continue
+		}
+		files, err := expandGlob(filename)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no files match %s", filename)
+		}
+		root := globRoot(filename)
+		for _, file := range files {
+			filenames = append(filenames, file)
+			baseDirs = append(baseDirs, root)
+		}
+	}
+	c.inputFilenames = filenames
+	c.baseDirs = baseDirs
+	return nil
+}
+
+// excluded checks if the given path matches one of the given exclude patterns.
+// A pattern without a "/" is matched against the base name, like "node_modules" or "*_test.png",
+// while other patterns are matched against the path relative to the given base directory.
+func excluded(baseDir, path string, patterns []string) bool {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.ReplaceAll(pattern, "\\", "/"), "/")
+		if !strings.Contains(pattern, "/") {
+			if ok, err := filepath.Match(pattern, filepath.Base(path)); err == nil && ok {
+				return true
+			}
+			continue
+		}
+		if matchParts(strings.Split(pattern, "/"), parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList is a flag.Value that collects the values of a flag that can be given several times
+type stringList []string
+
+// String returns the collected values, separated by commas
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+// Set adds the given value
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// exists checks if the given path exists
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
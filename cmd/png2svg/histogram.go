@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xyproto/png2svg"
+)
+
+// histogram prints the colors of the input image and how many pixels have each color, with the most
+// frequent color first, instead of converting the image. Options that change the colors, like -colors
+// and -posterize, are applied first, so that their effect can be seen.
+func histogram(c *Config) error {
+	img, err := c.readImage(c.inputFilename)
+	if err != nil {
+		return err
+	}
+	pi := png2svg.NewPixelImage(img, false)
+	pi.SetOptions(&c.opts)
+	colors := pi.Histogram()
+	total := 0
+	for _, cf := range colors {
+		total += cf.Count
+	}
+	fmt.Printf("%-10s %10s %8s\n", "color", "pixels", "percent")
+	for _, cf := range colors {
+		fmt.Printf("%-10s %10d %7.2f%%\n", cf.Color, cf.Count, 100*float64(cf.Count)/float64(total))
+	}
+	fmt.Printf("%d colors, %d pixels that are not transparent\n", len(colors), total)
+	return nil
+}
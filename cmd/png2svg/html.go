@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// viewBoxSize finds the width and height in the viewBox of the given SVG image
+var viewBoxSize = regexp.MustCompile(`viewBox="[-\d.]+ [-\d.]+ ([\d.]+) ([\d.]+)"`)
+
+// zoomLevels are the zoom levels that can be selected in the HTML page, in addition to "fit"
+var zoomLevels = []int{1, 2, 4, 8}
+
+// htmlPage wraps the given SVG image in a standalone HTML page with a checkerboard background,
+// so that transparent regions are visible, and with zoom buttons that only use CSS
+func htmlPage(title string, svgData []byte) []byte {
+	// The XML prolog is not allowed within a HTML document
+	if i := bytes.Index(svgData, []byte("<svg")); i > 0 {
+		svgData = svgData[i:]
+	}
+	w, h := 0, 0
+	if m := viewBoxSize.FindSubmatch(svgData); m != nil {
+		fw, _ := strconv.ParseFloat(string(m[1]), 64)
+		fh, _ := strconv.ParseFloat(string(m[2]), 64)
+		w, h = int(math.Round(fw)), int(math.Round(fh))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&buf, "<title>%s</title>\n", html.EscapeString(title))
+	buf.WriteString(`<style>
+body { margin: 0; font-family: sans-serif; background: #333; color: #eee; }
+nav { padding: 0.5em; }
+nav label { padding: 0.2em 0.6em; cursor: pointer; border: 1px solid #888; border-radius: 3px; }
+input { display: none; }
+main { overflow: auto; padding: 0.5em; }
+main svg { display: block; shape-rendering: crispEdges; width: 100%; height: auto; background: repeating-conic-gradient(#ccc 0% 25%, #fff 0% 50%) 0 0 / 16px 16px; }
+#fit:checked ~ nav label[for=fit] { background: #666; }
+`)
+	for _, zoom := range zoomLevels {
+		fmt.Fprintf(&buf, "#zoom%d:checked ~ nav label[for=zoom%d] { background: #666; }\n", zoom, zoom)
+		fmt.Fprintf(&buf, "#zoom%d:checked ~ main svg { width: %dpx; height: %dpx; }\n", zoom, w*zoom, h*zoom)
+	}
+	buf.WriteString("</style>\n</head>\n<body>\n")
+	buf.WriteString("<input type=\"radio\" name=\"zoom\" id=\"fit\" checked>\n")
+	for _, zoom := range zoomLevels {
+		fmt.Fprintf(&buf, "<input type=\"radio\" name=\"zoom\" id=\"zoom%d\">\n", zoom)
+	}
+	fmt.Fprintf(&buf, "<nav>%s (%dx%d) <label for=\"fit\">fit</label>", html.EscapeString(title), w, h)
+	for _, zoom := range zoomLevels {
+		fmt.Fprintf(&buf, " <label for=\"zoom%d\">%dx</label>", zoom, zoom)
+	}
+	buf.WriteString("</nav>\n<main>\n")
+	buf.Write(svgData)
+	buf.WriteString("\n</main>\n</body>\n</html>\n")
+	return buf.Bytes()
+}
+
+// htmlTitle returns the title of the HTML page for the given input filename
+func htmlTitle(inputFilename string) string {
+	if inputFilename == "-" {
+		return "png2svg"
+	}
+	if isURL(inputFilename) {
+		return urlBase(inputFilename)
+	}
+	return filepath.Base(inputFilename)
+}
@@ -4,81 +4,391 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"math/rand"
+	"image"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/xyproto/png2svg"
 )
 
-func init() {
-	// Seed the random number generator
-	rand.Seed(time.Now().UTC().UnixNano())
-}
-
 // Config contains the results of parsing the flags and arguments
 type Config struct {
-	inputFilename         string
-	outputFilename        string
-	colorOptimize         bool
-	colorPink             bool
-	limit                 bool
-	quantize              bool
-	singlePixelRectangles bool
-	verbose               bool
-	version               bool
+	inputFilename  string
+	inputFilenames []string
+	baseDirs       []string // the base directory of each input file that was found with a glob pattern
+	output         string
+	outputDir      string
+	outputFile     string
+	compare        string
+	expand         string
+	strategy       string
+	quantizer      string
+	palette        string
+	duotone        string
+	binaryColors   string
+	maxBytes       int
+	icoSize        int
+	maxDepth       int
+	noRecurse      bool
+	excludes       stringList
+	offset         string
+	verify         bool
+	attributes     stringList
+	colorMap       stringList
+	noNamedColors  bool
+	followSymlinks bool
+	ext            string
+	noAutoRotate   bool
+	timeout        time.Duration
+	animate        bool
+	tui            bool
+	histogram      bool
+	version        bool
+	zip            bool
+	gz             bool
+	html           bool
+	format         string
+	dataURI        bool
+	outputTemplate string
+	reportMapping  bool // report "input -> output" when writing each file in batch mode
+	force          bool
+	skipExisting   bool
+	mode           string
+	urlEncode      bool
+	opts           png2svg.Options
 }
 
 // NewConfigFromFlags returns a Config struct, a quit message (for -v) and/or an error
 func NewConfigFromFlags() (*Config, string, error) {
-	var c Config
-
-	flag.StringVar(&c.outputFilename, "o", "./", "SVG output filename")
-	flag.BoolVar(&c.singlePixelRectangles, "p", false, "use only single pixel rectangles")
-	flag.BoolVar(&c.colorPink, "c", false, "color expanded rectangles pink")
-	flag.BoolVar(&c.verbose, "v", false, "verbose")
-	flag.BoolVar(&c.version, "V", false, "version")
-	flag.BoolVar(&c.limit, "l", false, "limit colors to a maximum of 4096 (#abcdef -> #ace)")
-	flag.BoolVar(&c.quantize, "q", false, "deprecated (same as -l)")
-	flag.BoolVar(&c.colorOptimize, "z", false, "deprecated (same as -l)")
+	return NewConfigFromArgs(os.Args[0], os.Args[1:], flag.ExitOnError)
+}
 
-	flag.Parse()
+// NewConfigFromArgs parses the given command line arguments, and returns
+// a Config struct, a quit message (for -v) and/or an error
+func NewConfigFromArgs(name string, arguments []string, errorHandling flag.ErrorHandling) (*Config, string, error) {
+	c := Config{opts: *png2svg.NewOptions()}
+
+	fs := flag.NewFlagSet(name, errorHandling)
+
+	fs.StringVar(&c.inputFilename, "i", "", "input filename (- for stdin), instead of giving it as an argument")
+	fs.StringVar(&c.output, "o", "", "SVG output filename, or output directory if it ends with / or the input is a directory")
+	fs.StringVar(&c.outputDir, "outdir", "", "output directory, for both single files and directories")
+	fs.StringVar(&c.outputDir, "O", "", "output directory, where the directory structure of the input directories is mirrored (same as -outdir)")
+	fs.StringVar(&c.outputTemplate, "o-template", "", "output filename template, with {name}, {dir}, {width}, {height} and {colors}, like {name}_{width}x{height}.svg")
+	fs.StringVar(&c.outputFile, "outfile", "", "SVG output filename, when converting a single file (- for stdout)")
+	fs.BoolVar(&c.force, "f", false, "overwrite existing output files")
+	fs.BoolVar(&c.force, "force", false, "overwrite existing output files (same as -f)")
+	fs.BoolVar(&c.skipExisting, "skip-existing", false, "skip input files where the output file already exists")
+	fs.StringVar(&c.mode, "mode", "0644", "permissions for the output files, in octal (new directories are also searchable, like 0755, and 0 keeps the permissions of existing files and uses the umask for new ones)")
+	fs.BoolVar(&c.opts.SinglePixelRectangles, "p", false, "use only single pixel rectangles")
+	fs.BoolVar(&c.opts.Runs, "rle", false, "cover the image with horizontal runs of the same color, which is much faster for huge images")
+	fs.BoolVar(&c.opts.Optimal, "optimal", false, "partition each region of one color into the smallest possible number of rectangles that do not overlap (expanded rectangles may overlap, and are sometimes fewer)")
+	fs.BoolVar(&c.opts.Quadtree, "quadtree", false, "cover the image by splitting it into squares with sizes that are powers of two, until each square has one color")
+	fs.BoolVar(&c.opts.LargestFirst, "largest", false, "cover the image by placing the largest rectangle of one color first, again and again, which often gives fewer rectangles")
+	fs.BoolVar(&c.opts.ColorPink, "c", false, "color expanded rectangles pink")
+	fs.BoolVar(&c.opts.Verbose, "v", false, "verbose")
+	fs.BoolVar(&c.version, "V", false, "version")
+	fs.BoolVar(&c.opts.LimitColors, "l", false, "limit colors to a maximum of 4096 (#abcdef -> #ace)")
+	fs.BoolVar(&c.opts.Quantize, "q", false, "deprecated (same as -l)")
+	fs.BoolVar(&c.opts.ColorOptimize, "z", false, "deprecated (same as -l)")
+	fs.BoolVar(&c.opts.Pretty, "pretty", false, "place each element on a separate line, with indentation, for reading and diffing")
+	fs.BoolVar(&c.opts.Minify, "min", false, "leave out the optional parts of the SVG image, like the XML prolog")
+	fs.BoolVar(&c.opts.Paths, "paths", false, "draw all rectangles of one color with a single <path>, for smaller SVG images")
+	fs.BoolVar(&c.opts.CompoundPaths, "compound", false, "like -paths, but merge touching rectangles of the same color into outlines with holes")
+	fs.BoolVar(&c.opts.Contours, "contours", false, "like -compound, but trace each connected region of one color and draw it with a <path> of its own")
+	fs.Float64Var(&c.opts.Simplify, "simplify", 0, "remove corners that are at most this many pixels away from the outlines of -compound or -contours, for fewer nodes (implies -compound)")
+	fs.BoolVar(&c.opts.Smooth, "smooth", false, "draw the outlines of -compound or -contours with curves, for scaling up low resolution logos (implies -compound and -simplify 1)")
+	fs.BoolVar(&c.opts.Outline, "outline", false, "only draw the outlines of the regions of each color, for coloring books or pen plotters (implies -compound)")
+	fs.Float64Var(&c.opts.StrokeWidth, "stroke-width", 1, "width of the outlines given by -outline, in pixels")
+	fs.StringVar(&c.opts.StrokeColor, "stroke", "", "color of the outlines given by -outline, like #000 (the default is the color of each region)")
+	fs.Float64Var(&c.opts.CornerThreshold, "corners", 80, "how many degrees a smoothed outline has to turn for the corner to be kept sharp (180 smooths all corners)")
+	fs.BoolVar(&c.opts.Layered, "layers", false, "place the shapes of each color in an Inkscape layer of its own, labeled with the color")
+	fs.BoolVar(&c.opts.IDs, "ids", false, "give each color group and each shape a stable id, like c0 and c0-r17, for animating regions with CSS or JavaScript")
+	fs.StringVar(&c.opts.IDPrefix, "id-prefix", "", "prefix for the ids given by -ids, like logo- for logo-c0-r17 (implies -ids)")
+	fs.BoolVar(&c.opts.Classes, "css", false, "use one CSS class per color, defined in a <style> block, instead of fill attributes")
+	fs.BoolVar(&c.opts.Reuse, "use", false, "draw rectangles that have the same size as many others with <use>, referring to <defs>")
+	fs.Float64Var(&c.opts.Width, "width", 0, "rendered width of the SVG image, in pixels, while the viewBox is the size of the image (0 keeps the aspect ratio)")
+	fs.Float64Var(&c.opts.Height, "height", 0, "rendered height of the SVG image, in pixels (0 keeps the aspect ratio)")
+	fs.Float64Var(&c.opts.Scale, "scale", 0, "rendered size of the SVG image, as a factor of the size of the image, like 32 for 16x16 -> 512x512")
+	fs.StringVar(&c.offset, "offset", "", "move all coordinates by this offset, like 100,50, for placing the SVG image into another document")
+	fs.Float64Var(&c.opts.UnitScale, "unitscale", 1, "the size of one pixel in the coordinates of the SVG image")
+	fs.IntVar(&c.opts.Precision, "precision", 3, "the number of decimals that moved or scaled coordinates are rounded to")
+	fs.Float64Var(&c.opts.DPI, "dpi", 0, "give the width and height of the SVG image in -unit, where one pixel is 1/dpi inches (0 for pixels)")
+	fs.StringVar(&c.opts.Unit, "unit", "mm", "physical unit of the width and height, when -dpi is given: mm, cm or in")
+	fs.StringVar(&c.opts.Title, "title", "", "add a <title> element, where {name} is the input filename without the extension (the default is the input filename, if -desc is given)")
+	fs.StringVar(&c.opts.Description, "desc", "", "add a <desc> element, where {name} is the input filename without the extension")
+	fs.BoolVar(&c.opts.Metadata, "metadata", false, "add a <metadata> element with the png2svg version, the input filename, the flags and the number of rectangles")
+	fs.StringVar(&c.opts.Profile, "profile", "", "SVG profile: tiny (SVG Tiny 1.2, without -css and -checker), 1.1 or 2 (the default is a Tiny 1.2 header)")
+	fs.StringVar(&c.opts.ShapeRendering, "shape-rendering", "crispEdges", "shape-rendering attribute of the <svg> tag (an empty string leaves it out)")
+	fs.StringVar(&c.opts.ImageRendering, "image-rendering", "", "image-rendering attribute of the <svg> tag, like pixelated")
+	fs.StringVar(&c.opts.PreserveAspectRatio, "aspect", "", "preserveAspectRatio attribute of the <svg> tag, like \"xMidYMid meet\" or none")
+	fs.StringVar(&c.opts.Role, "role", "", "role attribute of the <svg> tag, like img, so that screen readers treat the SVG image as one image")
+	fs.StringVar(&c.opts.AriaLabel, "aria-label", "", "aria-label attribute of the <svg> tag, for screen readers, where {name} is the input filename without the extension")
+	fs.StringVar(&c.opts.Lang, "lang", "", "lang attribute of the <svg> tag, like en, for the language of the title, description and aria-label")
+	fs.Var(&c.attributes, "attr", "additional attribute of the <svg> tag, like class=icon (can be given several times)")
+	fs.BoolVar(&c.verify, "verify", false, "draw the rectangles onto an image in memory and compare it with the input image, and fail if any pixels differ")
+	fs.BoolVar(&c.opts.DominantColor, "dominant", false, "draw the most frequent color as one background rectangle, and skip the pixels with that color (for images without transparency)")
+	fs.StringVar(&c.opts.Transparent, "transparent", "", "make the pixels with this key color fully transparent, like #ff00ff for sprites that use magenta as the transparent color")
+	fs.StringVar(&c.opts.Background, "background", "", "blend transparent and semi-transparent pixels with this color, like #ffffff, for renderers that can not handle opacity")
+	fs.BoolVar(&c.opts.SkipTransparent, "skip-transparent", false, "never draw anything for fully transparent pixels, also with -background and -block, for icons and sprites that are placed over other backgrounds")
+	fs.BoolVar(&c.noNamedColors, "no-named-colors", false, "write all colors as hex colors, instead of using the CSS color names that are shorter, like red for #f00")
+	fs.BoolVar(&c.opts.HexAlpha, "hexalpha", false, "give the alpha value of semi-transparent pixels as #rrggbbaa colors, instead of with fill-opacity")
+	fs.Var(&c.colorMap, "map", "replace one color with another, like #f00=#00f, for recoloring sprites (can be given several times)")
+	fs.Float64Var(&c.opts.Brightness, "brightness", 0, "add this much to the brightness, from -1 to 1, before the colors are reduced")
+	fs.Float64Var(&c.opts.Contrast, "contrast", 0, "increase the contrast, from -1 (flat gray) to 1, before the colors are reduced")
+	fs.Float64Var(&c.opts.Gamma, "gamma", 1, "make the dark parts lighter with a gamma above 1, or darker with a gamma below 1")
+	fs.BoolVar(&c.opts.Grayscale, "grayscale", false, "convert the colors to gray, which merges colors that only differ in hue, for fewer colors and rectangles")
+	fs.StringVar(&c.opts.GrayscaleWeights, "gray-weights", "rec709", "how -grayscale, -duotone and -sepia weigh the red, green and blue values: rec709, rec601 or average")
+	fs.StringVar(&c.duotone, "duotone", "", "map the gray level of each pixel onto a ramp between two colors, like \"#123,#fed\" for dark,light")
+	fs.IntVar(&c.opts.Threshold, "threshold", 0, "give the pixels with a gray level below this level (1 to 255) the dark color and the rest the light color, for line drawings (0 is off)")
+	fs.StringVar(&c.binaryColors, "threshold-colors", "", "the dark and light color for -threshold, like \"#123,#fed\" (the default is black and white)")
+	fs.BoolVar(&c.opts.Sepia, "sepia", false, "map the gray level of each pixel onto a ramp from dark brown to light cream (like -duotone \""+png2svg.SepiaDark+","+png2svg.SepiaLight+"\")")
+	fs.IntVar(&c.opts.Posterize, "posterize", 0, "reduce each of red, green and blue to this many levels, which often turns antialiasing and noise into flat regions (0 for all levels)")
+	fs.IntVar(&c.opts.Colors, "colors", 0, "reduce the image to at most this many colors, with color quantization (see -quantizer), for far fewer rectangles in photos (0 for all colors)")
+	fs.StringVar(&c.quantizer, "quantizer", "mediancut", "how -colors reduces the colors: mediancut, octree, which is faster and uses less memory for huge images, or kmeans, which is slower but gives the best colors")
+	fs.IntVar(&c.opts.KMeansIterations, "iterations", 10, "the largest number of iterations for -quantizer kmeans")
+	fs.StringVar(&c.palette, "palette", "", "give each pixel the closest color in this palette file (.gpl, .hex or .act), to match a brand palette or a retro system palette, or web216 for the 216 web safe colors")
+	fs.BoolVar(&c.opts.Dither, "dither", false, "dither the colors given by -colors or -palette with Floyd-Steinberg dithering, so that gradients look smooth from a distance (gives more rectangles)")
+	fs.IntVar(&c.opts.Block, "block", 0, "give each NxN block of pixels the average color of the block, for smaller SVG images of photos and screenshots")
+	fs.BoolVar(&c.opts.BlockMajority, "majority", false, "use the most frequent color of each block given by -block, instead of the average color")
+	fs.BoolVar(&c.opts.Dots, "dots", false, "draw a <circle> in the middle of each pixel, or each block given by -block, instead of rectangles")
+	fs.Float64Var(&c.opts.DotRadius, "dot-radius", 0.5, "radius of the dots given by -dots, as a fraction of the pixel or block size (0.5 makes the dots touch)")
+	fs.BoolVar(&c.opts.DotLuminance, "dot-luminance", false, "scale the dots given by -dots, so that dark pixels get larger dots, like a halftone print")
+	fs.Float64Var(&c.opts.Hexagons, "hex", 0, "draw the image with hexagons that have this radius, in pixels, instead of rectangles, where each hexagon gets the color of the pixel at its center")
+	fs.Float64Var(&c.opts.Rx, "rx", 0, "give the rectangles rounded corners with this horizontal radius, in pixels")
+	fs.Float64Var(&c.opts.Ry, "ry", 0, "vertical radius of the rounded corners given by -rx, in pixels (the default is the same as -rx)")
+	fs.Float64Var(&c.opts.Gap, "gap", 0, "shrink each rectangle by half this many pixels on every side, for mosaic-like output")
+	fs.StringVar(&c.opts.Grout, "grout", "", "fill the gaps given by -gap with this color, like #fff")
+	fs.BoolVar(&c.opts.Checker, "checker", false, "draw a checkerboard behind transparent regions")
+	fs.StringVar(&c.opts.XMLEncoding, "encoding", "UTF-8", "encoding declared in the XML prolog, where text that is not ASCII is written as character references for other encodings than UTF-8 (an empty string leaves out the prolog)")
+	fs.StringVar(&c.expand, "expand", "right", "preferred direction when expanding rectangles: right, down or balanced")
+	fs.StringVar(&c.strategy, "strategy", "", "how to cover the image: right, down or balanced (expanding rectangles, like -expand), rle (like -rle), optimal (like -optimal), quadtree (like -quadtree), largest (like -largest) or pixels (like -p)")
+	fs.StringVar(&c.compare, "compare", "", "compare two sets of flags, like \"-l|-expand down\", and report the results")
+	fs.IntVar(&c.maxBytes, "maxbytes", 0, "reduce the quality until the SVG image is at most this many bytes (0 for no limit)")
+	fs.StringVar(&c.ext, "ext", "png", "comma separated list of file extensions to convert, when converting a directory (like png,jpg,gif,bmp,tiff,ico,ff,ppm)")
+	fs.IntVar(&c.maxDepth, "maxdepth", -1, "descend at most this many directory levels below an input directory (-1 for no limit)")
+	fs.BoolVar(&c.noRecurse, "no-recurse", false, "only convert the files at the top level of an input directory (same as -maxdepth 0)")
+	fs.Var(&c.excludes, "exclude", "skip files and directories that match this glob pattern, when converting a directory (can be given several times)")
+	fs.BoolVar(&c.followSymlinks, "follow-symlinks", false, "follow symbolic links to directories, when converting a directory")
+	fs.IntVar(&c.icoSize, "icosize", 0, "the width of the image to use from an ICO file with several sizes (0 for the largest)")
+	fs.BoolVar(&c.noAutoRotate, "no-autorotate", false, "do not rotate JPEG images according to their EXIF orientation")
+	fs.BoolVar(&c.animate, "animate", false, "convert all frames of an animated GIF or PNG image to an animated SVG image")
+	fs.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout when downloading an image from a http:// or https:// URL")
+	fs.BoolVar(&c.histogram, "histogram", false, "print the colors of the image and how many pixels have each color, instead of converting it (after -colors, -posterize and the like)")
+	fs.BoolVar(&c.tui, "tui", false, "interactively try out settings and see the results")
+	fs.BoolVar(&c.gz, "gz", false, "write gzip-compressed SVG images (.svgz), this is also done if the output filename ends with .svgz")
+	fs.BoolVar(&c.html, "html", false, "wrap the SVG image in a HTML page with a checkerboard background and zoom buttons, for previewing")
+	fs.StringVar(&c.format, "format", "", "output format: svg, pdf or eps (the default is to use the extension of the output filename, or svg)")
+	fs.BoolVar(&c.dataURI, "datauri", false, "print the SVG image as a data:image/svg+xml;base64 URI, instead of writing a file")
+	fs.BoolVar(&c.urlEncode, "urlencode", false, "URL encode the data URI given by -datauri, instead of using base64")
+	fs.BoolVar(&c.zip, "zip", false, "write the SVG images to a ZIP archive (use -o - for stdout)")
+
+	// Flags may also be given after the input filenames, like "a.png b.png -O outdir/"
+	args := arguments
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		if parsed := len(args) - len(rest); parsed > 0 && args[parsed-1] == "--" {
+			// Everything after "--" is an input filename
+			c.inputFilenames = append(c.inputFilenames, rest...)
+			break
+		}
+		c.inputFilenames = append(c.inputFilenames, rest[0])
+		args = rest[1:]
+	}
 
 	if c.version {
 		return nil, png2svg.VersionString, nil
 	}
 
-	c.limit = c.limit || c.quantize || c.colorOptimize
+	// Record the flags that were given, for the metadata
+	var flags []string
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && value == "true" {
+			flags = append(flags, "-"+f.Name)
+			return
+		}
+		if strings.ContainsAny(value, " \"") || value == "" {
+			value = strconv.Quote(value)
+		}
+		flags = append(flags, "-"+f.Name+"="+value)
+	})
+	c.opts.Flags = strings.Join(flags, " ")
 
-	if c.colorPink {
-		c.singlePixelRectangles = false
+	if c.noRecurse {
+		c.maxDepth = 0
 	}
 
-	args := flag.Args()
-	if len(args) == 0 {
-		return nil, "", errors.New("an input PNG filename is required")
+	fileMode, err := parseMode(c.mode)
+	if err != nil {
+		return nil, "", err
+	}
+	c.opts.FileMode = fileMode
+
+	switch c.format = strings.ToLower(c.format); c.format {
+	case "", "svg", "pdf", "eps":
+	default:
+		return nil, "", fmt.Errorf("unknown output format: %s", c.format)
+	}
 
+	if c.opts.IDPrefix != "" {
+		c.opts.IDs = true
+	}
+
+	c.opts.NamedColors = !c.noNamedColors
+	c.opts.AutoRotate = !c.noAutoRotate
+
+	// The layers are only useful in editors if they are labeled
+	c.opts.InkscapeLabels = c.opts.Layered
+
+	expandOrder, err := png2svg.ParseExpandOrder(c.expand)
+	if err != nil {
+		return nil, "", err
+	}
+	c.opts.ExpandOrder = expandOrder
+
+	// -strategy takes the same names as -expand, and overrides it
+	if c.strategy != "" {
+		strategy, err := png2svg.ParseStrategy(c.strategy)
+		if err != nil {
+			return nil, "", err
+		}
+		png2svg.WithStrategy(strategy)(&c.opts)
+	}
+
+	quantizer, err := png2svg.ParseQuantizer(c.quantizer)
+	if err != nil {
+		return nil, "", err
+	}
+	c.opts.Quantizer = quantizer
+
+	if c.duotone != "" {
+		fields := strings.Split(c.duotone, ",")
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("expected two colors like dark,light, got: %s", c.duotone)
+		}
+		c.opts.DuotoneDark, c.opts.DuotoneLight = strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+	}
+
+	if c.binaryColors != "" {
+		fields := strings.Split(c.binaryColors, ",")
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("expected two colors like dark,light, got: %s", c.binaryColors)
+		}
+		c.opts.ThresholdDark, c.opts.ThresholdLight = strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+	}
+
+	switch c.palette {
+	case "":
+	case "web216":
+		c.opts.Palette = png2svg.WebSafePalette()
+	default:
+		palette, err := png2svg.ReadPalette(c.palette)
+		if err != nil {
+			return nil, "", err
+		}
+		c.opts.Palette = palette
+	}
+
+	if c.offset != "" {
+		fields := strings.Split(c.offset, ",")
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("expected an offset like x,y, got: %s", c.offset)
+		}
+		if c.opts.OffsetX, err = strconv.ParseFloat(strings.TrimSpace(fields[0]), 64); err != nil {
+			return nil, "", fmt.Errorf("invalid offset: %s", c.offset)
+		}
+		if c.opts.OffsetY, err = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64); err != nil {
+			return nil, "", fmt.Errorf("invalid offset: %s", c.offset)
+		}
+	}
+
+	for _, mapping := range c.colorMap {
+		fields := strings.SplitN(mapping, "=", 2)
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("expected a color mapping like #from=#to, got: %s", mapping)
+		}
+		if c.opts.ColorMap == nil {
+			c.opts.ColorMap = make(map[string]string)
+		}
+		c.opts.ColorMap[fields[0]] = fields[1]
+	}
+	for _, attribute := range c.attributes {
+		fields := strings.SplitN(attribute, "=", 2)
+		if len(fields) != 2 {
+			return nil, "", fmt.Errorf("expected an attribute like name=value, got: %s", attribute)
+		}
+		if c.opts.RootAttributes == nil {
+			c.opts.RootAttributes = make(map[string]string)
+		}
+		c.opts.RootAttributes[fields[0]] = fields[1]
+	}
+
+	if err := c.opts.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if c.inputFilename != "" {
+		c.inputFilenames = append([]string{c.inputFilename}, c.inputFilenames...)
+	}
+	if len(c.inputFilenames) == 0 {
+		return nil, "", errors.New("an input PNG, JPEG, GIF, BMP, TIFF, ICO, farbfeld or PNM filename is required (or - for stdin)")
+	}
+	for i, filename := range c.inputFilenames {
+		c.inputFilenames[i] = strings.ReplaceAll(filename, "\\", "/")
+	}
+	if err := c.expandInputs(); err != nil {
+		return nil, "", err
+	}
+	c.inputFilename = c.inputFilenames[0]
+	multiple := len(c.inputFilenames) > 1
+
+	// -o is an alias for either -outdir or -outfile
+	c.output = strings.ReplaceAll(c.output, "\\", "/")
+	if c.output != "" {
+		if c.output != "-" && !c.zip && (strings.HasSuffix(c.output, "/") || isDir(c.output) || isDir(c.inputFilename) || multiple) {
+			c.outputDir = c.output
+		} else {
+			c.outputFile = c.output
+		}
+	}
+	c.outputDir = strings.ReplaceAll(c.outputDir, "\\", "/")
+	c.outputFile = strings.ReplaceAll(c.outputFile, "\\", "/")
+	if multiple && c.outputFile != "" && !c.zip {
+		return nil, "", errors.New("an output filename can only be given for a single input file, use -O for an output directory")
+	}
+
+	// PDF and EPS documents are written as they are
+	if format := c.outputFormat(c.outputPath("", c.inputFilename)); format != "svg" {
+		switch {
+		case c.dataURI:
+			return nil, "", errors.New("-datauri can not be used with the " + format + " output format")
+		case c.html:
+			return nil, "", errors.New("-html can not be used with the " + format + " output format")
+		case c.maxBytes > 0:
+			return nil, "", errors.New("-maxbytes can not be used with the " + format + " output format")
+		}
+	}
+
+	// Turn off verbose messages, so that they don't end up in the SVG output
+	if c.dataURI || c.outputPath("", c.inputFilename) == "-" {
+		c.opts.Verbose = false
 	}
-	c.inputFilename = args[0]
-	c.inputFilename = strings.ReplaceAll(c.inputFilename, "\\", "/")
-	c.outputFilename = strings.ReplaceAll(c.outputFilename, "\\", "/")
 
 	return &c, "", nil
 }
 
 // Run performs the user-selected operations
 func Run() error {
-	var (
-		box          *png2svg.Box
-		x, y         int
-		expanded     bool
-		lastx, lasty int
-		lastLine     int // one message per line / y coordinate
-		done         bool
-	)
-
 	c, quitMessage, err := NewConfigFromFlags()
 	if err != nil {
 		return err
@@ -86,114 +396,463 @@ func Run() error {
 		fmt.Println(quitMessage)
 		return nil
 	}
+	if c.compare != "" {
+		return compare(c)
+	}
+	if c.tui {
+		return tui(c)
+	}
+	if c.histogram {
+		return histogram(c)
+	}
+	if len(c.inputFilenames) > 1 {
+		return convertMany(c)
+	}
+	return convertInput(c)
+}
+
+// convertMany converts all the given input files and directories
+func convertMany(c *Config) error {
+	if c.zip {
+		var baseDirs, fileList []string
+		for i, filename := range c.inputFilenames {
+			if !isDir(filename) {
+				baseDirs = append(baseDirs, c.baseDirs[i])
+				fileList = append(fileList, filename)
+				continue
+			}
+			files, err := c.findFiles(filename)
+			if err != nil {
+				return err
+			}
+			for _, file := range files {
+				baseDirs = append(baseDirs, filename)
+				fileList = append(fileList, file)
+			}
+		}
+		return convertToZip(c, baseDirs, fileList)
+	}
+	for i, filename := range c.inputFilenames {
+		c.inputFilename = filename
+		var err error
+		if filename == "-" || isURL(filename) || isDir(filename) {
+			err = convertInput(c)
+		} else {
+			err = convertMapped(c, c.baseDirs[i], filename)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	return nil
+}
+
+// convertMapped converts the given input file, which is in the given base directory (or ""),
+// to the same relative location below the output directory. The mapping is reported when the file is written.
+func convertMapped(c *Config, baseDir, filename string) error {
+	c.reportMapping = true
+	return convertOne(c, c.outputPath(baseDir, filename))
+}
+
+// convertInput converts c.inputFilename, which may be a file, a directory, a URL or - for stdin
+func convertInput(c *Config) error {
+	if c.inputFilename == "-" || isURL(c.inputFilename) {
+		return convertOne(c, c.outputPath("", c.inputFilename))
+	}
 	state, err := os.Stat(c.inputFilename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
+	if c.zip {
+		if !state.IsDir() {
+			return convertToZip(c, []string{""}, []string{c.inputFilename})
+		}
+		fileList, err := c.findFiles(c.inputFilename)
+		if err != nil {
+			return err
+		}
+		baseDirs := make([]string, len(fileList))
+		for i := range baseDirs {
+			baseDirs[i] = c.inputFilename
+		}
+		return convertToZip(c, baseDirs, fileList)
+	}
 	if state.IsDir() {
-		fileList, err := GetAllFile(c.inputFilename)
+		fileList, err := c.findFiles(c.inputFilename)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error: %s\n", err)
 			os.Exit(1)
 		}
+		// Convert as many files as possible, and report the ones that failed at the end
 		baseName := c.inputFilename
+		failed := 0
 		for _, file := range fileList {
-			fmt.Println("file: ", file)
 			c.inputFilename = file
-			convertOne(c, done, x, y, lastx, lasty, lastLine, box, expanded, c.outputFilename, file[len(baseName):strings.LastIndex(file, ".png")]+".svg")
+			if err := convertMapped(c, baseName, file); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s: %s\n", file, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d files could not be converted", failed, len(fileList))
 		}
 		return nil
 	}
 
-	return convertOne(c, done, x, y, lastx, lasty, lastLine, box, expanded, "", c.outputFilename)
+	return convertOne(c, c.outputPath("", c.inputFilename))
+}
+
+// readImage reads the given image filename, or an image from stdin if the filename is "-",
+// or downloads the image if the filename is a http:// or https:// URL
+func (c *Config) readImage(filename string) (image.Image, error) {
+	if filename == "-" {
+		return png2svg.ReadImageFrom(os.Stdin, c.opts.AutoRotate, c.opts.Verbose)
+	}
+	if isURL(filename) {
+		body, err := download(filename, c.timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return png2svg.ReadImageFrom(body, c.opts.AutoRotate, c.opts.Verbose)
+	}
+	if c.icoSize > 0 && hasExtension(filename, []string{".ico", ".cur"}) {
+		return png2svg.ReadICO(filename, c.icoSize, c.opts.Verbose)
+	}
+	return png2svg.ReadImage(filename, c.opts.AutoRotate, c.opts.Verbose)
+}
+
+// isDir checks if the given path is an existing directory
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
 }
 
-func GetAllFile(pathname string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(pathname, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() || !strings.HasSuffix(path, ".png") {
+// outputPath returns the SVG output filename for the given input filename.
+// baseDir is the input directory, when converting a directory, or an empty string.
+// Files from a directory are placed in the same relative location below the output directory.
+func (c *Config) outputPath(baseDir, inputFilename string) string {
+	if inputFilename == "-" && c.outputFile == "" {
+		// Read from stdin, write to stdout
+		return "-"
+	}
+	if baseDir == "" && c.outputFile != "" {
+		if c.outputFile == "-" || c.outputDir == "" || filepath.IsAbs(c.outputFile) {
+			return c.outputFile
+		}
+		return filepath.ToSlash(filepath.Join(c.outputDir, c.outputFile))
+	}
+	name := filepath.Base(inputFilename)
+	if isURL(inputFilename) {
+		name = urlBase(inputFilename)
+	}
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, inputFilename); err == nil {
+			name = rel
+		}
+	}
+	if c.outputTemplate != "" {
+		return c.templatePath(baseDir, inputFilename, name)
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name)) + c.svgExt()
+	return filepath.ToSlash(filepath.Join(c.outputDir, name))
+}
+
+// svgExt returns the file extension for the SVG output files, which is ".pdf" or ".eps"
+// for those output formats, ".html" if -html is given, or ".svgz" if -gz is given
+func (c *Config) svgExt() string {
+	if c.format == "pdf" || c.format == "eps" {
+		return "." + c.format
+	}
+	if c.html {
+		return ".html"
+	}
+	if c.gz {
+		return ".svgz"
+	}
+	return ".svg"
+}
+
+// extensions returns the file extensions given with -ext, in lowercase and with a leading "."
+// Giving "jpg" also includes ".jpeg".
+func (c *Config) extensions() []string {
+	var exts []string
+	for _, ext := range strings.Split(c.ext, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" {
+			continue
+		}
+		exts = append(exts, "."+ext)
+		if ext == "jpg" {
+			exts = append(exts, ".jpeg")
+		}
+	}
+	return exts
+}
+
+// findFiles returns the files in the given directory that should be converted,
+// according to the -ext, -maxdepth, -no-recurse, -exclude and -follow-symlinks flags
+func (c *Config) findFiles(dir string) ([]string, error) {
+	return GetAllFile(dir, c.extensions(), c.maxDepth, c.excludes, c.followSymlinks)
+}
+
+// GetAllFile returns all files in the given directory and its subdirectories
+// that has one of the given file extensions. Subdirectories that are more than
+// maxDepth levels below the given directory are skipped, unless maxDepth is negative.
+// Files and directories that match one of the given exclude patterns are also skipped.
+// If followSymlinks is true, symbolic links to directories are followed, except for
+// links that point back to a directory that is already being walked.
+func GetAllFile(pathname string, exts []string, maxDepth int, excludes []string, followSymlinks bool) ([]string, error) {
+	var (
+		files []string
+		walk  func(dir string) error
+	)
+	// The real paths of the directories that are currently being walked, for detecting loops
+	walking := make(map[string]bool)
+	walk = func(dir string) error {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if walking[realDir] {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s, since it links back to %s\n", dir, realDir)
 			return nil
 		}
-		files = append(files, strings.ReplaceAll(path, "\\", "/"))
-		return nil
-	})
-	if err != nil {
+		walking[realDir] = true
+		defer delete(walking, realDir)
+		return filepath.Walk(realDir, func(realPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(realDir, realPath)
+			if err != nil {
+				return err
+			}
+			// The path below the given directory, with any followed symbolic links kept as they are
+			path := filepath.Join(dir, rel)
+			if path != filepath.Clean(pathname) && excluded(pathname, path, excludes) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if followSymlinks && info.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Stat(realPath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: skipping %s: %s\n", path, err)
+					return nil
+				}
+				if target.IsDir() {
+					if maxDepth >= 0 && depth(pathname, path) > maxDepth {
+						return nil
+					}
+					return walk(path)
+				}
+			}
+			if info.IsDir() {
+				if maxDepth >= 0 && realPath != realDir && depth(pathname, path) > maxDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !hasExtension(path, exts) {
+				return nil
+			}
+			files = append(files, strings.ReplaceAll(path, "\\", "/"))
+			return nil
+		})
+	}
+	if err := walk(pathname); err != nil {
 		return nil, err
 	}
 	return files, nil
 }
 
-func convertOne(c *Config, done bool, x int, y int, lastx int, lasty int, lastLine int, box *png2svg.Box, expanded bool, outputBasePath string, outputFilename string) error {
-	img, err := png2svg.ReadPNG(c.inputFilename, c.verbose)
-	if err != nil {
-		return err
+// depth returns how many directory levels the given directory is below the given base directory
+func depth(baseDir, dir string) int {
+	rel, err := filepath.Rel(baseDir, dir)
+	if err != nil || rel == "." {
+		return 0
 	}
+	return len(strings.Split(filepath.ToSlash(rel), "/"))
+}
 
-	height := img.Bounds().Max.Y - img.Bounds().Min.Y
+// hasExtension checks if the given filename ends with one of the given lowercase extensions,
+// regardless of the case of the filename, so that "IMAGE.PNG" matches ".png"
+func hasExtension(filename string, exts []string) bool {
+	filename = strings.ToLower(filename)
+	for _, ext := range exts {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
 
-	pi := png2svg.NewPixelImage(img, c.verbose)
-	pi.SetColorOptimize(c.limit)
+// convertImage covers the given image with rectangles, according to the given configuration
+func convertImage(c *Config, img image.Image) *png2svg.PixelImage {
+	pi := png2svg.NewPixelImage(img, c.opts.Verbose)
+	pi.SetOptions(&c.opts)
+	if c.opts.Metadata {
+		pi.SetMetadata(true, htmlTitle(c.inputFilename), c.opts.Flags)
+	}
+	if c.opts.Title != "" || c.opts.Description != "" {
+		title := c.opts.Title
+		if title == "" {
+			title = htmlTitle(c.inputFilename)
+		}
+		pi.SetTitle(c.expandName(title))
+		pi.SetDescription(c.expandName(c.opts.Description))
+	}
+	if c.opts.AriaLabel != "" {
+		pi.SetRootAttribute("aria-label", c.expandName(c.opts.AriaLabel))
+	}
+	pi.Cover(c.opts.SinglePixelRectangles, c.opts.ColorPink)
+	return pi
+}
 
-	if c.verbose {
-		fmt.Print("Placing rectangles... 0%")
+// convertOne converts c.inputFilename and writes the SVG image to outputFilename
+func convertOne(c *Config, outputFilename string) error {
+	// Check the output filename before converting, unless it depends on the conversion
+	if !c.dataURI && !strings.Contains(outputFilename, "{") {
+		if err := c.checkOverwrite(outputFilename); err == errSkip {
+			fmt.Printf("Skipping %s, since %s already exists\n", c.inputFilename, outputFilename)
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if c.animate {
+		return convertAnimation(c, outputFilename)
+	}
+	if animated, err := png2svg.IsAnimated(c.inputFilename); err == nil && animated && c.inputFilename != "-" {
+		fmt.Fprintf(os.Stderr, "warning: %s is animated, but only the first frame is converted (use -animate for all frames)\n", c.inputFilename)
 	}
 
-	percentage := 0
-	lastPercentage := 0
+	img, err := c.readImage(c.inputFilename)
+	if err != nil {
+		return err
+	}
 
-	// Cover pixels by creating expanding rectangles, as long as there are uncovered pixels
-	for !c.singlePixelRectangles && !done {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
 
-		// Select the first uncovered pixel, searching from the given coordinate
-		x, y = pi.FirstUncovered(lastx, lasty)
+	if c.maxBytes > 0 {
+		if outputFilename == "-" {
+			// Turn off verbose messages, so that they don't end up in the SVG output
+			c.opts.Verbose = false
+		}
+		pi, data := fitToMaxBytes(c, img)
+		if c.verify {
+			if err := verify(c, pi); err != nil {
+				return err
+			}
+		}
+		return c.writeData(expandImagePlaceholders(outputFilename, w, h, countFillColors(data)), data)
+	}
 
-		if c.verbose && y != lastLine {
-			lastPercentage = percentage
-			percentage = int((float64(y) / float64(height)) * 100.0)
-			png2svg.Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
-			fmt.Printf("%d%%", percentage)
-			lastLine = y
+	pi := convertImage(c, img)
+	outputFilename = expandImagePlaceholders(outputFilename, w, h, pi.ColorCount())
+	if c.verify {
+		if err := verify(c, pi); err != nil {
+			return err
 		}
+	}
+	switch c.outputFormat(outputFilename) {
+	case "pdf":
+		data, err := pi.PDF()
+		if err != nil {
+			return err
+		}
+		return c.writeFile(outputFilename, data)
+	case "eps":
+		data, err := pi.EPS()
+		if err != nil {
+			return err
+		}
+		return c.writeFile(outputFilename, data)
+	}
+	if !pi.Done(0, 0) {
+		return errors.New("the SVG representation does not cover all pixels")
+	}
+	return c.writeData(outputFilename, pi.Bytes())
+}
 
-		// Create a box at that location
-		box = pi.CreateBox(x, y)
-		// Expand the box to the right and downwards, until it can not expand anymore
-		expanded = pi.Expand(box)
+// outputFormat returns the output format for the given output filename, which is the format
+// given with -format, or "pdf" or "eps" if the filename has that extension, or else "svg"
+func (c *Config) outputFormat(outputFilename string) string {
+	if c.format != "" {
+		return c.format
+	}
+	switch strings.ToLower(filepath.Ext(outputFilename)) {
+	case ".pdf":
+		return "pdf"
+	case ".eps":
+		return "eps"
+	}
+	return "svg"
+}
 
-		// NOTE: Random boxes gave worse results, even though they are expanding in all directions
-		// Create a random box
-		//box := pi.CreateRandomBox(false)
-		// Expand the box in all directions, until it can not expand anymore
-		//expanded = pi.ExpandRandom(box)
+// writeData writes the given SVG data to the given filename, or to stdout if the filename is "-".
+// The data is wrapped in a HTML page if -html is given.
+// The data is compressed with gzip if -gz is given, or if the filename ends with ".svgz".
+// If -datauri is given, a data URI is printed to stdout instead.
+func (c *Config) writeData(filename string, data []byte) error {
+	if c.dataURI {
+		fmt.Println(dataURI(data, c.urlEncode))
+		return nil
+	}
+	if c.html {
+		data = htmlPage(htmlTitle(c.inputFilename), data)
+	}
+	if c.gz || strings.HasSuffix(strings.ToLower(filename), ".svgz") {
+		var err error
+		if data, err = png2svg.SVGZ(data); err != nil {
+			return err
+		}
+	}
+	return c.writeFile(filename, data)
+}
 
-		// Use the expanded box. Color pink if it is > 1x1, and colorPink is true
-		pi.CoverBox(box, expanded && c.colorPink, c.limit)
+// errSkip is returned by checkOverwrite when an existing output file should be skipped
+var errSkip = errors.New("skip")
 
-		// Check if we are done, searching from the current x,y
-		done = pi.Done(x, y)
+// checkOverwrite returns an error if the given output file exists and -f is not given,
+// or errSkip if -skip-existing is given
+func (c *Config) checkOverwrite(filename string) error {
+	if c.force || filename == "-" || !exists(filename) {
+		return nil
 	}
-
-	if c.verbose {
-		png2svg.Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
-		fmt.Println("100%")
+	if c.skipExisting {
+		return errSkip
 	}
+	return fmt.Errorf("%s already exists, use -f to overwrite it", filename)
+}
 
-	if c.singlePixelRectangles {
-		// Cover all remaining pixels with rectangles of size 1x1
-		pi.CoverAllPixels()
+// writeFile writes the given data to the given filename, or to stdout if the filename is "-".
+// The directory of the file is created if it does not exist. The data is written to a temporary
+// file that is then renamed, so that an interrupted conversion never leaves a truncated file behind.
+func (c *Config) writeFile(filename string, data []byte) error {
+	if filename == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
 	}
-
-	// Write the SVG image to outputFilename
-	filename := outputBasePath + outputFilename
-	dir := filepath.Dir(filename)
-	os.MkdirAll(dir, os.ModePerm)
-	return pi.WriteSVG(filename)
+	if err := c.checkOverwrite(filename); err != nil {
+		if err == errSkip {
+			fmt.Printf("Skipping %s, since %s already exists\n", c.inputFilename, filename)
+			return nil
+		}
+		return err
+	}
+	if c.reportMapping {
+		fmt.Printf("%s -> %s\n", c.inputFilename, filename)
+	}
+	return png2svg.WriteFileAtomic(filename, data, c.opts.FileMode)
 }
 
 func main() {
 	if err := Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %s\n", strings.Title(err.Error()))
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	"github.com/xyproto/png2svg"
+)
+
+// attempt is one set of settings that is tried when fitting an SVG image within -maxbytes
+type attempt struct {
+	description string
+	limit       bool
+	levels      int // color levels per channel, 0 for all
+	scale       int // downscaling factor, 1 for none
+}
+
+// fitToMaxBytes converts the image with progressively more aggressive settings,
+// until the resulting SVG image is at most c.maxBytes bytes large.
+// The smallest result is returned, together with the PixelImage it came from,
+// and a warning is printed if it is still too large.
+func fitToMaxBytes(c *Config, img image.Image) (*png2svg.PixelImage, []byte) {
+	attempts := []attempt{
+		{"the given settings", c.opts.LimitColors, 0, 1},
+		{"4096 colors", true, 0, 1},
+		{"8 levels per channel", true, 8, 1},
+		{"4 levels per channel", true, 4, 1},
+		{"2 levels per channel", true, 2, 1},
+	}
+	for scale := 2; scale <= 16; scale *= 2 {
+		attempts = append(attempts, attempt{fmt.Sprintf("4 levels per channel, downscaled 1:%d", scale), true, 4, scale})
+	}
+
+	// Convert quietly, and report each attempt instead
+	verbose := c.opts.Verbose
+	defer func() { c.opts.Verbose = verbose }()
+	c.opts.Verbose = false
+	limit, levels := c.opts.LimitColors, c.opts.Posterize
+	defer func() { c.opts.LimitColors, c.opts.Posterize = limit, levels }()
+
+	// The downscaled images are rendered at the size of the original image
+	b := img.Bounds()
+	displayW, displayH := float64(b.Dx()), float64(b.Dy())
+	switch {
+	case c.opts.Scale > 0:
+		displayW, displayH = displayW*c.opts.Scale, displayH*c.opts.Scale
+	case c.opts.Width > 0 && c.opts.Height > 0:
+		displayW, displayH = c.opts.Width, c.opts.Height
+	case c.opts.Width > 0:
+		displayW, displayH = c.opts.Width, c.opts.Width*displayH/displayW
+	case c.opts.Height > 0:
+		displayW, displayH = c.opts.Height*displayW/displayH, c.opts.Height
+	}
+
+	var (
+		smallest   []byte
+		smallestPi *png2svg.PixelImage
+	)
+	for _, a := range attempts {
+		src := img
+		if a.scale > 1 {
+			if b.Dx()/a.scale < 1 || b.Dy()/a.scale < 1 {
+				break
+			}
+			src = downscale(src, a.scale)
+		}
+		if a.levels > 0 {
+			c.opts.Posterize = a.levels
+		}
+		c.opts.LimitColors = a.limit
+		pi := convertImage(c, src)
+		if a.scale > 1 {
+			pi.SetDisplaySize(displayW, displayH)
+		}
+		data := pi.Bytes()
+		if verbose {
+			fmt.Printf("Tried %s: %d bytes\n", a.description, len(data))
+		}
+		if smallest == nil || len(data) < len(smallest) {
+			smallest, smallestPi = data, pi
+		}
+		if len(data) <= c.maxBytes {
+			if verbose {
+				fmt.Printf("Using %s\n", a.description)
+			}
+			return pi, data
+		}
+	}
+	fmt.Fprintf(os.Stderr, "warning: could not fit the SVG image within %d bytes, the smallest result is %d bytes\n", c.maxBytes, len(smallest))
+	return smallestPi, smallest
+}
+
+// downscale returns a smaller copy of the image, where each factor x factor block
+// of pixels is replaced by the average color of the block. The blocks at the right and
+// bottom edges may be smaller, if the size of the image is not divisible by the factor.
+func downscale(img image.Image, factor int) image.Image {
+	b := img.Bounds()
+	w, h := (b.Dx()+factor-1)/factor, (b.Dy()+factor-1)/factor
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, bl, a, n uint32
+			for dy := 0; dy < factor && y*factor+dy < b.Dy(); dy++ {
+				for dx := 0; dx < factor && x*factor+dx < b.Dx(); dx++ {
+					n++
+					c := color.NRGBAModel.Convert(img.At(b.Min.X+x*factor+dx, b.Min.Y+y*factor+dy)).(color.NRGBA)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					bl += uint32(c.B)
+					a += uint32(c.A)
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
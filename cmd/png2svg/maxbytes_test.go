@@ -0,0 +1,44 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDownscaleEdges(t *testing.T) {
+	// A 5x3 image, where the last column is white and the rest is black
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 5; x++ {
+			c := color.NRGBA{0, 0, 0, 0xff}
+			if x == 4 {
+				c = color.NRGBA{0xff, 0xff, 0xff, 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	tests := []struct {
+		factor int
+		w, h   int
+		// the red value of the top right and bottom right pixels
+		topRight, bottomRight uint8
+	}{
+		{1, 5, 3, 0xff, 0xff},
+		{2, 3, 2, 0xff, 0xff},
+		{4, 2, 1, 0xff, 0xff},
+		{3, 2, 1, 0x7f, 0x7f},
+	}
+	for _, test := range tests {
+		small := downscale(img, test.factor)
+		b := small.Bounds()
+		if b.Dx() != test.w || b.Dy() != test.h {
+			t.Fatalf("factor %d: expected %dx%d, got %dx%d", test.factor, test.w, test.h, b.Dx(), b.Dy())
+		}
+		topRight := color.NRGBAModel.Convert(small.At(b.Max.X-1, 0)).(color.NRGBA)
+		bottomRight := color.NRGBAModel.Convert(small.At(b.Max.X-1, b.Max.Y-1)).(color.NRGBA)
+		if topRight.R != test.topRight || bottomRight.R != test.bottomRight || topRight.A != 0xff {
+			t.Errorf("factor %d: expected the right edge to be %#x and %#x, got %v and %v", test.factor, test.topRight, test.bottomRight, topRight, bottomRight)
+		}
+	}
+}
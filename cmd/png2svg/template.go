@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fillAttribute finds the fill colors in a SVG image
+var fillAttribute = regexp.MustCompile(`fill="([^"]+)"`)
+
+// templatePath returns the output filename for the given input filename, according to -o-template.
+// {name} is the input filename without the extension, and {dir} is the directory of the input file,
+// relative to the input directory if baseDir is not empty. The {width}, {height} and {colors}
+// placeholders are kept as they are, since they are not known until the image has been converted.
+func (c *Config) templatePath(baseDir, inputFilename, name string) string {
+	dir := filepath.Dir(inputFilename)
+	if isURL(inputFilename) {
+		dir = "."
+	} else if baseDir != "" {
+		dir = filepath.Dir(name)
+	}
+	r := strings.NewReplacer(
+		"{name}", strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)),
+		"{dir}", filepath.ToSlash(dir),
+	)
+	return filepath.ToSlash(filepath.Join(c.outputDir, r.Replace(c.outputTemplate)))
+}
+
+// expandName replaces the {name} placeholder in the given text with the input filename, without the extension
+func (c *Config) expandName(text string) string {
+	name := htmlTitle(c.inputFilename)
+	return strings.Replace(text, "{name}", strings.TrimSuffix(name, filepath.Ext(name)), -1)
+}
+
+// expandImagePlaceholders replaces the {width}, {height} and {colors} placeholders
+// in the given output filename with the size of the image and the number of fill colors
+func expandImagePlaceholders(filename string, w, h, colors int) string {
+	if !strings.Contains(filename, "{") {
+		return filename
+	}
+	return strings.NewReplacer(
+		"{width}", strconv.Itoa(w),
+		"{height}", strconv.Itoa(h),
+		"{colors}", strconv.Itoa(colors),
+	).Replace(filename)
+}
+
+// countFillColors returns the number of unique fill colors in the given SVG image
+func countFillColors(svgData []byte) int {
+	colors := make(map[string]bool)
+	for _, m := range fillAttribute.FindAllSubmatch(svgData, -1) {
+		colors[string(m[1])] = true
+	}
+	return len(colors)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xyproto/png2svg"
+)
+
+const tuiHelp = `Commands:
+  l                            toggle limiting the colors to 4096
+  p                            toggle single pixel rectangles
+  expand right|down|balanced   set the preferred expansion direction
+  levels N                     use N color levels per channel (0 for all)
+  w [filename]                 write the current SVG image to file
+  h                            show this help
+  q                            quit`
+
+// tui loads the input image and lets the user toggle settings interactively,
+// while showing the number of rectangles, colors and bytes for each change.
+func tui(c *Config) error {
+	if c.inputFilename == "-" {
+		return errors.New("-tui reads commands from stdin, so the image can not be read from stdin")
+	}
+	img, err := c.readImage(c.inputFilename)
+	if err != nil {
+		return err
+	}
+	// Progress information would drown the results
+	c.opts.Verbose = false
+
+	var svgData []byte
+	update := func() {
+		pi := convertImage(c, img)
+		svgData = pi.Bytes()
+		fmt.Printf("[l=%v p=%v expand=%s levels=%d] rects: %d, colors: %d, bytes: %d\n", c.opts.LimitColors, c.opts.SinglePixelRectangles, c.opts.ExpandOrder, c.opts.Posterize, pi.RectangleCount(), pi.ColorCount(), len(svgData))
+	}
+
+	fmt.Println(tuiHelp)
+	update()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// The settings are restored if the change gives an invalid combination
+		previous := c.opts
+		switch fields[0] {
+		case "l":
+			c.opts.LimitColors = !c.opts.LimitColors
+		case "p":
+			c.opts.SinglePixelRectangles = !c.opts.SinglePixelRectangles
+		case "expand":
+			if len(fields) < 2 {
+				fmt.Println("expand needs a direction")
+				continue
+			}
+			order, err := png2svg.ParseExpandOrder(fields[1])
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			c.opts.ExpandOrder = order
+		case "levels":
+			var (
+				n   int
+				err error
+			)
+			if len(fields) > 1 {
+				n, err = strconv.Atoi(fields[1])
+			}
+			if err != nil || n == 1 || n < 0 || n > 256 {
+				fmt.Println("levels must be 0 (for all) or a number from 2 to 256")
+				continue
+			}
+			c.opts.Posterize = n
+		case "w":
+			filename := c.outputPath("", c.inputFilename)
+			if len(fields) > 1 {
+				filename = fields[1]
+			}
+			if err := c.writeData(filename, svgData); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("Wrote %s\n", filename)
+			}
+			continue
+		case "h", "?", "help":
+			fmt.Println(tuiHelp)
+			continue
+		case "q", "quit", "exit":
+			return nil
+		default:
+			fmt.Printf("unknown command: %s (h for help)\n", fields[0])
+			continue
+		}
+		if err := c.opts.Validate(); err != nil {
+			fmt.Println(err)
+			c.opts = previous
+			continue
+		}
+		update()
+	}
+}
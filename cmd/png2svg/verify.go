@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xyproto/png2svg"
+)
+
+// maxReportedDiffs is the number of differing pixels that are listed when the verification fails
+const maxReportedDiffs = 5
+
+// verify checks that the rectangles of the given PixelImage draw the same pixels as c.inputFilename
+func verify(c *Config, pi *png2svg.PixelImage) error {
+	diffs := pi.Verify()
+	if len(diffs) == 0 {
+		if c.opts.Verbose {
+			fmt.Printf("Verified %s: all pixels are the same\n", c.inputFilename)
+		}
+		return nil
+	}
+	list := ""
+	for i, p := range diffs {
+		if i == maxReportedDiffs {
+			list += ", ..."
+			break
+		}
+		if i > 0 {
+			list += ", "
+		}
+		list += fmt.Sprintf("(%d,%d)", p.X, p.Y)
+	}
+	return fmt.Errorf("verification of %s failed: %d pixels differ, at %s", c.inputFilename, len(diffs), list)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xyproto/png2svg"
+)
+
+// convertToZip converts the given PNG files and writes the SVG images to a ZIP archive,
+// either to stdout (if the output filename is "-") or to the output filename.
+// The entry names keep the directory structure, relative to the base directory of each file
+// in baseDirs, which is an empty string for files that are not found in a directory.
+func convertToZip(c *Config, baseDirs, files []string) (err error) {
+	if c.outputFile == "" {
+		return errors.New("-zip needs an output filename, or - for stdout")
+	}
+
+	var w io.Writer
+	if c.outputFile == "-" {
+		w = os.Stdout
+		// Turn off verbose messages, so that they don't end up in the ZIP archive
+		c.opts.Verbose = false
+	} else {
+		filename := c.outputPath("", c.inputFilename)
+		if err := c.checkOverwrite(filename); err != nil {
+			if err == errSkip {
+				fmt.Printf("Skipping %s, since it already exists\n", filename)
+				return nil
+			}
+			return err
+		}
+		f, err := png2svg.CreateTemp(filename, c.opts.FileMode)
+		if err != nil {
+			return err
+		}
+		// The ZIP archive is only renamed into place if everything succeeds
+		defer func() {
+			err = png2svg.CommitTemp(f, filename, c.opts.FileMode, err)
+		}()
+		w = f
+	}
+
+	zw := zip.NewWriter(w)
+	for i, file := range files {
+		// The title, aria-label and metadata are for this file
+		c.inputFilename = file
+		img, err := c.readImage(file)
+		if err != nil {
+			return err
+		}
+		pi := convertImage(c, img)
+		if !pi.Done(0, 0) {
+			return errors.New("the SVG representation does not cover all pixels")
+		}
+		name := zipEntryName(baseDirs[i], file)
+		entry, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(pi.Bytes()); err != nil {
+			return err
+		}
+		if c.opts.Verbose {
+			fmt.Printf("Added %s\n", name)
+		}
+	}
+	// Closing the ZIP writer writes the central directory, without it the archive is truncated
+	return zw.Close()
+}
+
+// zipEntryName returns the name of the ZIP archive entry for the given file, which is the path
+// relative to the given base directory, with forward slashes and a .svg extension.
+// The filename is used if the base directory is empty or does not contain the file.
+func zipEntryName(baseDir, file string) string {
+	name := filepath.Base(file)
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, file); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	name = filepath.ToSlash(name)
+	return strings.TrimSuffix(name, path.Ext(name)) + ".svg"
+}
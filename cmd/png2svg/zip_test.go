@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestZipEntryName(t *testing.T) {
+	tests := []struct {
+		baseDir, file, name string
+	}{
+		{"", "a.png", "a.svg"},
+		{"", "d/a.png", "a.svg"},
+		{"d", "d/a.png", "a.svg"},
+		{"d/", "d/sub/b.png", "sub/b.svg"},
+		{"./d", "d/sub/b.png", "sub/b.svg"},
+		{"d", "d/sub/b.png", "sub/b.svg"},
+		{"e", "d/a.png", "a.svg"},
+		{"d", "d/icon.x.png", "icon.x.svg"},
+	}
+	for _, test := range tests {
+		if name := zipEntryName(test.baseDir, test.file); name != test.name {
+			t.Errorf("%q in %q: expected %s, got %s", test.file, test.baseDir, test.name, name)
+		}
+	}
+}
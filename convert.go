@@ -0,0 +1,731 @@
+package png2svg
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Option is a function that modifies the conversion Options
+type Option func(*Options)
+
+// WithColorLimit limits the colors to a maximum of 4096 (#abcdef -> #ace)
+func WithColorLimit(enabled bool) Option {
+	return func(o *Options) {
+		o.LimitColors = enabled
+	}
+}
+
+// WithSinglePixel uses only 1x1 rectangles, instead of expanding them
+func WithSinglePixel(enabled bool) Option {
+	return func(o *Options) {
+		o.SinglePixelRectangles = enabled
+	}
+}
+
+// WithColorPink colors the expanded rectangles pink
+func WithColorPink(enabled bool) Option {
+	return func(o *Options) {
+		o.ColorPink = enabled
+	}
+}
+
+// WithExpandOrder sets the direction that rectangles prefer to grow in
+func WithExpandOrder(order ExpandOrder) Option {
+	return func(o *Options) {
+		o.ExpandOrder = order
+	}
+}
+
+// WithStrategy selects how the image is covered with rectangles, like the -strategy flag.
+// See ParseStrategy for finding a Strategy by name.
+func WithStrategy(strategy Strategy) Option {
+	return func(o *Options) {
+		if order, ok := strategy.ExpandOrder(); ok {
+			o.ExpandOrder = order
+			return
+		}
+		switch strategy {
+		case RunsStrategy:
+			o.Runs = true
+		case OptimalStrategy:
+			o.Optimal = true
+		case QuadtreeStrategy:
+			o.Quadtree = true
+		case LargestFirstStrategy:
+			o.LargestFirst = true
+		case PixelsStrategy:
+			o.SinglePixelRectangles = true
+		}
+	}
+}
+
+// WithAutoRotate rotates and flips JPEG images according to their EXIF orientation, when Convert reads
+// the image. This is the default.
+func WithAutoRotate(enabled bool) Option {
+	return func(o *Options) {
+		o.AutoRotate = enabled
+	}
+}
+
+// WithFileMode sets the permissions of the files that Convert writes, like 0644
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *Options) {
+		o.FileMode = mode
+	}
+}
+
+// WithChecker draws a checkerboard behind transparent regions
+func WithChecker(enabled bool) Option {
+	return func(o *Options) {
+		o.Checker = enabled
+	}
+}
+
+// WithXMLEncoding sets the encoding declared in the XML prolog
+func WithXMLEncoding(encoding string) Option {
+	return func(o *Options) {
+		o.XMLEncoding = encoding
+	}
+}
+
+// WithLayered places the rectangles of each color in a separate group
+func WithLayered(enabled bool) Option {
+	return func(o *Options) {
+		o.Layered = enabled
+	}
+}
+
+// WithIDs gives each color group and each shape a stable id that starts with the given prefix, like "c0-r17"
+func WithIDs(prefix string) Option {
+	return func(o *Options) {
+		o.IDs = true
+		o.IDPrefix = prefix
+	}
+}
+
+// WithInkscapeLabels turns the layers into Inkscape layers, labeled with the color
+func WithInkscapeLabels(enabled bool) Option {
+	return func(o *Options) {
+		o.InkscapeLabels = enabled
+	}
+}
+
+// WithPretty places each element on a separate line, with indentation
+func WithPretty(enabled bool) Option {
+	return func(o *Options) {
+		o.Pretty = enabled
+	}
+}
+
+// WithMinify leaves out the optional parts of the SVG document
+func WithMinify(enabled bool) Option {
+	return func(o *Options) {
+		o.Minify = enabled
+	}
+}
+
+// WithPaths draws all rectangles of one color with a single path
+func WithPaths(enabled bool) Option {
+	return func(o *Options) {
+		o.Paths = enabled
+	}
+}
+
+// WithCompoundPaths merges touching rectangles of the same color into outlines with holes
+func WithCompoundPaths(enabled bool) Option {
+	return func(o *Options) {
+		o.CompoundPaths = enabled
+	}
+}
+
+// WithContours draws each connected region of one color with a path of its own
+func WithContours(enabled bool) Option {
+	return func(o *Options) {
+		o.Contours = enabled
+	}
+}
+
+// WithSimplify sets the tolerance, in pixels, for removing corners from the outlines
+// of compound paths and contours
+func WithSimplify(tolerance float64) Option {
+	return func(o *Options) {
+		o.Simplify = tolerance
+	}
+}
+
+// WithSmooth draws the outlines of compound paths and contours with curves
+func WithSmooth(enabled bool) Option {
+	return func(o *Options) {
+		o.Smooth = enabled
+	}
+}
+
+// WithCornerThreshold sets how much, in degrees, a smoothed outline has to turn for the corner to be kept sharp
+func WithCornerThreshold(degrees float64) Option {
+	return func(o *Options) {
+		o.CornerThreshold = degrees
+	}
+}
+
+// WithOutline only draws the outlines of the regions of each color, as stroked paths
+func WithOutline(enabled bool) Option {
+	return func(o *Options) {
+		o.Outline = enabled
+	}
+}
+
+// WithStroke sets the width, in pixels, and the color of the outlines.
+// An empty color gives each outline the color of its region.
+func WithStroke(width float64, color string) Option {
+	return func(o *Options) {
+		o.StrokeWidth = width
+		o.StrokeColor = color
+	}
+}
+
+// WithDots draws a circle in the middle of each pixel, or each block given by WithBlock, instead of rectangles.
+// The radius is a fraction of the cell size. If byLuminance is true, dark cells get larger dots.
+func WithDots(radius float64, byLuminance bool) Option {
+	return func(o *Options) {
+		o.Dots = true
+		o.DotRadius = radius
+		o.DotLuminance = byLuminance
+	}
+}
+
+// WithHexagons draws the image with hexagons that have the given radius, in pixels, instead of rectangles
+func WithHexagons(radius float64) Option {
+	return func(o *Options) {
+		o.Hexagons = radius
+	}
+}
+
+// WithCornerRadius gives the rectangles rounded corners, with the given horizontal and vertical radius, in pixels
+func WithCornerRadius(rx, ry float64) Option {
+	return func(o *Options) {
+		o.Rx = rx
+		o.Ry = ry
+	}
+}
+
+// WithGap shrinks each rectangle by half the gap, in pixels, on every side,
+// and fills the gaps with the grout color, if it is not empty
+func WithGap(gap float64, grout string) Option {
+	return func(o *Options) {
+		o.Gap = gap
+		o.Grout = grout
+	}
+}
+
+// WithClasses replaces the fill attributes with classes, defined in a <style> block
+func WithClasses(enabled bool) Option {
+	return func(o *Options) {
+		o.Classes = enabled
+	}
+}
+
+// WithReuse draws rectangles that have the same size as many others with <use> elements
+func WithReuse(enabled bool) Option {
+	return func(o *Options) {
+		o.Reuse = enabled
+	}
+}
+
+// WithShapeRendering sets the shape-rendering attribute of the <svg> tag
+func WithShapeRendering(value string) Option {
+	return func(o *Options) {
+		o.ShapeRendering = value
+	}
+}
+
+// WithImageRendering sets the image-rendering attribute of the <svg> tag
+func WithImageRendering(value string) Option {
+	return func(o *Options) {
+		o.ImageRendering = value
+	}
+}
+
+// WithPreserveAspectRatio sets the preserveAspectRatio attribute of the <svg> tag
+func WithPreserveAspectRatio(value string) Option {
+	return func(o *Options) {
+		o.PreserveAspectRatio = value
+	}
+}
+
+// WithAccessibility sets the role, aria-label and lang attributes of the <svg> tag, like "img",
+// a short text for screen readers and "en". Empty values leave out the attributes.
+func WithAccessibility(role, label, lang string) Option {
+	return func(o *Options) {
+		o.Role = role
+		o.AriaLabel = label
+		o.Lang = lang
+	}
+}
+
+// WithRootAttribute sets an additional attribute of the <svg> tag
+func WithRootAttribute(name, value string) Option {
+	return func(o *Options) {
+		if o.RootAttributes == nil {
+			o.RootAttributes = make(map[string]string)
+		}
+		o.RootAttributes[name] = value
+	}
+}
+
+// WithDisplaySize sets the rendered width and height of the SVG image, in pixels.
+// If one of them is 0, it is calculated from the other one, keeping the aspect ratio.
+func WithDisplaySize(width, height float64) Option {
+	return func(o *Options) {
+		o.Width = width
+		o.Height = height
+	}
+}
+
+// WithScale sets the rendered size of the SVG image to the size of the image times the given factor
+func WithScale(scale float64) Option {
+	return func(o *Options) {
+		o.Scale = scale
+	}
+}
+
+// WithDPI gives the width and height of the SVG image in the given unit: "mm", "cm" or "in",
+// where one pixel is 1/dpi inches
+func WithDPI(dpi float64, unit string) Option {
+	return func(o *Options) {
+		o.DPI = dpi
+		o.Unit = unit
+	}
+}
+
+// WithTitle adds a <title> element with the given text
+func WithTitle(title string) Option {
+	return func(o *Options) {
+		o.Title = title
+	}
+}
+
+// WithDescription adds a <desc> element with the given text
+func WithDescription(description string) Option {
+	return func(o *Options) {
+		o.Description = description
+	}
+}
+
+// WithMetadata adds a <metadata> element with the png2svg version, the given source
+// filename and flags and the number of rectangles
+func WithMetadata(source, flags string) Option {
+	return func(o *Options) {
+		o.Metadata = true
+		o.Source = source
+		o.Flags = flags
+	}
+}
+
+// WithProfile selects the SVG profile: "tiny", "1.1" or "2"
+func WithProfile(profile string) Option {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+// WithDominantColor draws the most frequent color as one rectangle across the whole image,
+// so that no other rectangles are needed for the pixels with that color
+func WithDominantColor(enabled bool) Option {
+	return func(o *Options) {
+		o.DominantColor = enabled
+	}
+}
+
+// WithNamedColors writes colors that have a shorter CSS color name by that name, like "red" instead of "#f00".
+// This is the default.
+func WithNamedColors(enabled bool) Option {
+	return func(o *Options) {
+		o.NamedColors = enabled
+	}
+}
+
+// WithHexAlpha gives the alpha value of semi-transparent rectangles at the end of the fill color,
+// like #rrggbbaa, instead of with a fill-opacity attribute
+func WithHexAlpha(enabled bool) Option {
+	return func(o *Options) {
+		o.HexAlpha = enabled
+	}
+}
+
+// WithTransparent makes the pixels that have the given key color, on the form "#rrggbb" or "#rgb",
+// fully transparent, like for sprites that use magenta as the transparent color
+func WithTransparent(color string) Option {
+	return func(o *Options) {
+		o.Transparent = color
+	}
+}
+
+// WithBackground blends all pixels that are not fully opaque with the given color,
+// on the form "#rrggbb" or "#rgb", so that the SVG image has no transparency
+func WithBackground(color string) Option {
+	return func(o *Options) {
+		o.Background = color
+	}
+}
+
+// WithSkipTransparent keeps the fully transparent pixels transparent, so that no shapes are drawn for them,
+// even with WithBackground or WithBlock
+func WithSkipTransparent(enabled bool) Option {
+	return func(o *Options) {
+		o.SkipTransparent = enabled
+	}
+}
+
+// WithOffset moves all coordinates by the given offset, after they are scaled
+func WithOffset(x, y float64) Option {
+	return func(o *Options) {
+		o.OffsetX = x
+		o.OffsetY = y
+	}
+}
+
+// WithUnitScale sets the size of one pixel in the coordinates of the SVG image
+func WithUnitScale(scale float64) Option {
+	return func(o *Options) {
+		o.UnitScale = scale
+	}
+}
+
+// WithPrecision sets the number of decimals that moved or scaled coordinates are rounded to
+func WithPrecision(decimals int) Option {
+	return func(o *Options) {
+		o.Precision = decimals
+	}
+}
+
+// WithRuns covers the image with horizontal runs of the same color, one row at the time,
+// instead of expanding rectangles. This is much faster for huge images.
+func WithRuns(enabled bool) Option {
+	return func(o *Options) {
+		o.Runs = enabled
+	}
+}
+
+// WithOptimal partitions each region of one color into the smallest possible number of rectangles
+// that do not overlap, instead of expanding rectangles, which may overlap
+func WithOptimal(enabled bool) Option {
+	return func(o *Options) {
+		o.Optimal = enabled
+	}
+}
+
+// WithQuadtree covers the image by recursively splitting it into squares, until each square has one color
+func WithQuadtree(enabled bool) Option {
+	return func(o *Options) {
+		o.Quadtree = enabled
+	}
+}
+
+// WithLargestFirst covers the image by placing the largest rectangle of one color first, again and again
+func WithLargestFirst(enabled bool) Option {
+	return func(o *Options) {
+		o.LargestFirst = enabled
+	}
+}
+
+// WithBlock gives each n×n block of pixels the average color of the block, or the most
+// frequent color if majority is true, before the image is covered with rectangles
+func WithBlock(n int, majority bool) Option {
+	return func(o *Options) {
+		o.Block = n
+		o.BlockMajority = majority
+	}
+}
+
+// WithColorMap replaces one color with another, like "#f00" with "#00f", before the image is covered
+// with rectangles. It can be given several times.
+func WithColorMap(from, to string) Option {
+	return func(o *Options) {
+		if o.ColorMap == nil {
+			o.ColorMap = make(map[string]string)
+		}
+		o.ColorMap[from] = to
+	}
+}
+
+// WithGrayscale converts the colors to gray, before the image is covered with rectangles.
+// The weights can be "rec709" (the default), "rec601" or "average", see ParseGrayscaleWeights.
+func WithGrayscale(weights string) Option {
+	return func(o *Options) {
+		o.Grayscale = true
+		o.GrayscaleWeights = weights
+	}
+}
+
+// WithDuotone maps the gray level of each pixel onto a ramp between the dark and light color,
+// like #000 and #fff, before the image is covered with rectangles
+func WithDuotone(dark, light string) Option {
+	return func(o *Options) {
+		o.DuotoneDark = dark
+		o.DuotoneLight = light
+	}
+}
+
+// WithSepia maps the gray level of each pixel onto a ramp from dark brown to light cream
+func WithSepia(enabled bool) Option {
+	return func(o *Options) {
+		o.Sepia = enabled
+	}
+}
+
+// WithBrightness adds the given brightness, from -1 to 1, to r, g and b, before the image is covered with rectangles
+func WithBrightness(brightness float64) Option {
+	return func(o *Options) {
+		o.Brightness = brightness
+	}
+}
+
+// WithContrast stretches r, g and b away from the middle, from -1 (flat gray) to 1,
+// before the image is covered with rectangles
+func WithContrast(contrast float64) Option {
+	return func(o *Options) {
+		o.Contrast = contrast
+	}
+}
+
+// WithGamma makes the dark parts of the image lighter if the gamma is above 1,
+// or darker if it is below 1, before the image is covered with rectangles
+func WithGamma(gamma float64) Option {
+	return func(o *Options) {
+		o.Gamma = gamma
+	}
+}
+
+// WithThreshold gives the pixels with a gray level below the given level, from 1 to 255, the dark color,
+// and the other pixels the light color, like "#000" and "#fff". Empty colors are black and white.
+func WithThreshold(level int, dark, light string) Option {
+	return func(o *Options) {
+		o.Threshold = level
+		o.ThresholdDark = dark
+		o.ThresholdLight = light
+	}
+}
+
+// WithPosterize reduces each of r, g and b to the given number of levels, before the image is covered with rectangles
+func WithPosterize(levels int) Option {
+	return func(o *Options) {
+		o.Posterize = levels
+	}
+}
+
+// WithColors reduces the image to at most n colors, with median cut quantization,
+// before the image is covered with rectangles
+func WithColors(n int) Option {
+	return func(o *Options) {
+		o.Colors = n
+	}
+}
+
+// WithQuantizer decides how the colors are reduced, when WithColors is used
+func WithQuantizer(quantizer Quantizer) Option {
+	return func(o *Options) {
+		o.Quantizer = quantizer
+	}
+}
+
+// WithKMeansIterations sets the largest number of iterations for the k-means quantizer
+func WithKMeansIterations(iterations int) Option {
+	return func(o *Options) {
+		o.KMeansIterations = iterations
+	}
+}
+
+// WithPalette gives each pixel the closest of the given colors, as r, g and b,
+// before the image is covered with rectangles
+func WithPalette(palette [][3]int) Option {
+	return func(o *Options) {
+		o.Palette = palette
+	}
+}
+
+// WithDither spreads the difference between the original colors and the colors given by WithColors
+// or WithPalette to the pixels around them, with Floyd-Steinberg dithering
+func WithDither(enabled bool) Option {
+	return func(o *Options) {
+		o.Dither = enabled
+	}
+}
+
+// WithVerbose outputs progress information to stdout
+func WithVerbose(enabled bool) Option {
+	return func(o *Options) {
+		o.Verbose = enabled
+	}
+}
+
+// Cover covers all pixels of this PixelImage with rectangles.
+// If singlePixelRectangles is true, only 1x1 rectangles are used.
+// If colorPink is true, the expanded rectangles are colored pink.
+// If the dominant color is enabled, it is drawn as the background first.
+func (pi *PixelImage) Cover(singlePixelRectangles, colorPink bool) {
+	if pi.dominant {
+		// Draw the most frequent color as the background
+		pi.CoverDominantColor()
+	}
+
+	if singlePixelRectangles {
+		// Cover all pixels with rectangles of size 1x1
+		pi.CoverAllPixels()
+		return
+	}
+
+	if pi.runs {
+		// Cover all pixels with horizontal runs of the same color
+		pi.CoverRuns()
+		return
+	}
+
+	if pi.optimal {
+		// Cover all pixels with as few rectangles as possible
+		pi.CoverOptimal()
+		return
+	}
+
+	if pi.quadtree {
+		// Cover all pixels with squares of one color
+		pi.CoverQuadtree()
+		return
+	}
+
+	if pi.largestFirst {
+		// Cover all pixels with the largest rectangles first
+		pi.CoverLargestFirst()
+		return
+	}
+
+	var (
+		box          *Box
+		x, y         int
+		expanded     bool
+		lastx, lasty int
+		lastLine     int // one message per line / y coordinate
+		done         bool
+	)
+
+	if pi.verbose {
+		fmt.Print("Placing rectangles... 0%")
+	}
+
+	percentage := 0
+	lastPercentage := 0
+
+	// All pixels may already be covered, like for transparent images, or when the dominant color is the only one
+	done = pi.Done(0, 0)
+
+	// Cover pixels by creating expanding rectangles, as long as there are uncovered pixels
+	for !done {
+
+		// Select the first uncovered pixel, searching from the given coordinate
+		x, y = pi.FirstUncovered(lastx, lasty)
+
+		if pi.verbose && y != lastLine {
+			lastPercentage = percentage
+			percentage = int((float64(y) / float64(pi.h)) * 100.0)
+			Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
+			fmt.Printf("%d%%", percentage)
+			lastLine = y
+		}
+
+		// Create a box at that location
+		box = pi.CreateBox(x, y)
+		// Expand the box to the right and downwards, until it can not expand anymore
+		expanded = pi.Expand(box)
+
+		// NOTE: Random boxes gave worse results, even though they are expanding in all directions
+		// Create a random box
+		//box := pi.CreateRandomBox(false)
+		// Expand the box in all directions, until it can not expand anymore
+		//expanded = pi.ExpandRandom(box)
+
+		// Use the expanded box. Color pink if it is > 1x1, and colorPink is true
+		pi.CoverBox(box, expanded && colorPink, pi.colorOptimize)
+
+		// The box only grows to the right and downwards, so all pixels before x,y are still covered,
+		// and the next search can start here. This matters for noisy images, like dithered ones.
+		lastx, lasty = x, y
+
+		// Check if we are done, searching from the current x,y
+		done = pi.Done(x, y)
+	}
+
+	if pi.verbose {
+		Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
+		fmt.Println("100%")
+	}
+}
+
+// ConvertImage converts the given image to an SVG image, using the given options
+func ConvertImage(img image.Image, opts ...Option) ([]byte, error) {
+	o := NewOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	pi := NewPixelImage(img, o.Verbose)
+	pi.SetOptions(o)
+	pi.Cover(o.SinglePixelRectangles, o.ColorPink)
+	return pi.Bytes(), nil
+}
+
+// Convert reads the given image file, converts it to an SVG image, using the given options, and writes
+// it to the given output file, like the png2svg utility does. The output is a PDF or EPS document if the
+// output filename ends with ".pdf" or ".eps", and the SVG image is compressed with gzip if it ends with
+// ".svgz". The input filename can be "-" for stdin, and the output filename can be "-" for stdout.
+// The output file is written like by WriteFileAtomic, with the permissions given by WithFileMode.
+func Convert(input, output string, opts ...Option) error {
+	o := NewOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	var (
+		img image.Image
+		err error
+	)
+	if input == "-" {
+		img, err = ReadImageFrom(os.Stdin, o.AutoRotate, o.Verbose)
+	} else {
+		img, err = ReadImage(input, o.AutoRotate, o.Verbose)
+		if o.Source == "" {
+			o.Source = filepath.Base(input)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	pi := NewPixelImage(img, o.Verbose)
+	pi.SetOptions(o)
+	pi.Cover(o.SinglePixelRectangles, o.ColorPink)
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".pdf":
+		data, err = pi.PDF()
+	case ".eps":
+		data, err = pi.EPS()
+	case ".svgz":
+		data, err = SVGZ(pi.Bytes())
+	default:
+		data = pi.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return WriteFileAtomic(output, data, o.FileMode)
+}
@@ -0,0 +1,408 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// uniformImage returns a w×h image where all pixels have the given color
+func uniformImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCoverDominantSolid(t *testing.T) {
+	for _, size := range []int{1, 4} {
+		svg, err := ConvertImage(uniformImage(size, size, color.NRGBA{0x12, 0x34, 0x56, 0xff}), WithDominantColor(true))
+		if err != nil {
+			t.Fatalf("%dx%d: %v", size, size, err)
+		}
+		if n := bytes.Count(svg, []byte("<rect")); n != 1 {
+			t.Errorf("%dx%d: expected only the background rectangle, got %d rectangles:\n%s", size, size, n, svg)
+		}
+	}
+}
+
+func TestTransparentKeyClearsImage(t *testing.T) {
+	svg, err := ConvertImage(uniformImage(4, 4, color.NRGBA{0xff, 0xff, 0xff, 0xff}), WithTransparent("#ffffff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(svg, []byte("<rect")) {
+		t.Errorf("expected an empty SVG image, got:\n%s", svg)
+	}
+}
+
+func TestConvertImageWithoutUncoveredPixels(t *testing.T) {
+	images := map[string]image.Image{
+		"transparent": uniformImage(4, 4, color.NRGBA{}),
+		"solid":       uniformImage(4, 4, color.NRGBA{0xff, 0, 0, 0xff}),
+		"single":      uniformImage(1, 1, color.NRGBA{0, 0, 0xff, 0xff}),
+	}
+	modes := map[string][]Option{
+		"default":   nil,
+		"dominant":  {WithDominantColor(true)},
+		"limit":     {WithColorLimit(true)},
+		"pixels":    {WithSinglePixel(true)},
+		"paths":     {WithPaths(true)},
+		"compound":  {WithCompoundPaths(true)},
+		"contours":  {WithContours(true)},
+		"smooth":    {WithCompoundPaths(true), WithSmooth(true)},
+		"outline":   {WithOutline(true)},
+		"classes":   {WithClasses(true)},
+		"reuse":     {WithReuse(true), WithProfile("2")},
+		"layers":    {WithLayered(true), WithIDs("")},
+		"colors":    {WithColors(4)},
+		"octree":    {WithColors(4), WithQuantizer(OctreeQuantizer)},
+		"kmeans":    {WithColors(4), WithQuantizer(KMeansQuantizer)},
+		"dither":    {WithColors(2), WithDither(true)},
+		"block":     {WithBlock(2, false)},
+		"majority":  {WithBlock(2, true)},
+		"dots":      {WithDots(0.4, true)},
+		"hexagons":  {WithHexagons(2)},
+		"rle":       {WithRuns(true)},
+		"optimal":   {WithOptimal(true)},
+		"quadtree":  {WithQuadtree(true)},
+		"largest":   {WithLargestFirst(true)},
+		"checker":   {WithChecker(true)},
+		"threshold": {WithThreshold(128, "", "")},
+	}
+	for imageName, img := range images {
+		for modeName, opts := range modes {
+			svg, err := ConvertImage(img, opts...)
+			if err != nil {
+				t.Errorf("%s image, %s: %v", imageName, modeName, err)
+				continue
+			}
+			if !bytes.Contains(svg, []byte("<svg")) {
+				t.Errorf("%s image, %s: not an SVG image:\n%s", imageName, modeName, svg)
+			}
+		}
+	}
+}
+
+func TestAnimatedSVGTransparentFrame(t *testing.T) {
+	a := &Animation{Delays: []time.Duration{time.Second, time.Second}}
+	var documents [][]byte
+	for _, c := range []color.NRGBA{{}, {0xff, 0, 0, 0xff}} {
+		svg, err := ConvertImage(uniformImage(2, 2, c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		documents = append(documents, svg)
+	}
+	svg, err := a.AnimatedSVG(documents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(svg, []byte("</g></svg>")) || bytes.Count(svg, []byte("<g visibility")) != 2 {
+		t.Errorf("expected two frames, got:\n%s", svg)
+	}
+}
+
+func TestAnimatedSVGUniqueIDs(t *testing.T) {
+	a := &Animation{Delays: []time.Duration{time.Second, time.Second}}
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"use", []Option{WithReuse(true), WithChecker(true)}},
+		{"layers", []Option{WithLayered(true), WithClasses(true)}},
+		{"ids", []Option{WithLayered(true), WithIDs("")}},
+	}
+	for _, test := range tests {
+		var documents [][]byte
+		for _, colors := range [][2]color.NRGBA{{{0xff, 0, 0, 0xff}, {0, 0, 0xff, 0xff}}, {{0, 0xff, 0, 0xff}, {0xff, 0xff, 0, 0xff}}} {
+			// Rows of alternating colors, with one transparent pixel, so that the checkerboard is drawn
+			img := image.NewNRGBA(image.Rect(0, 0, 3, 6))
+			for y := 0; y < 6; y++ {
+				for x := 0; x < 3; x++ {
+					img.SetNRGBA(x, y, colors[y%2])
+				}
+			}
+			img.SetNRGBA(2, 5, color.NRGBA{})
+			svg, err := ConvertImage(img, test.options...)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			documents = append(documents, svg)
+		}
+		svg, err := a.AnimatedSVG(documents)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		ids := make(map[string]bool)
+		for _, m := range regexp.MustCompile(` id="([^"]+)"`).FindAllSubmatch(svg, -1) {
+			id := string(m[1])
+			if ids[id] {
+				t.Errorf("%s: the id %s is used more than once in:\n%s", test.name, id, svg)
+			}
+			ids[id] = true
+		}
+		if len(ids) == 0 {
+			t.Errorf("%s: expected ids in:\n%s", test.name, svg)
+		}
+		for _, m := range regexp.MustCompile(`(?:href="#|url\(#)([^"')]+)`).FindAllSubmatch(svg, -1) {
+			if !ids[string(m[1])] {
+				t.Errorf("%s: the reference to %s has no matching id in:\n%s", test.name, m[1], svg)
+			}
+		}
+		// Each frame has classes of its own, since the same class may have different colors in each frame
+		for _, m := range regexp.MustCompile(` class="([^"]+)"`).FindAllSubmatch(svg, -1) {
+			if count := bytes.Count(svg, []byte("."+string(m[1])+"{")); count != 1 {
+				t.Errorf("%s: the class %s is defined %d times in:\n%s", test.name, m[1], count, svg)
+			}
+		}
+	}
+}
+
+func TestReuseHref(t *testing.T) {
+	// Four 2x1 rectangles of alternating colors, so that the same size is repeated
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 2; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(y%2) * 0xff, 0, 0, 0xff})
+		}
+	}
+	tests := []struct {
+		profile string
+		href    string
+		xlink   bool
+	}{
+		{"", `<use xlink:href="#`, true},
+		{"1.1", `<use xlink:href="#`, true},
+		{"2", `<use href="#`, false},
+	}
+	for _, test := range tests {
+		svg, err := ConvertImage(img, WithReuse(true), WithProfile(test.profile))
+		if err != nil {
+			t.Fatalf("profile %q: %v", test.profile, err)
+		}
+		if !bytes.Contains(svg, []byte(test.href)) {
+			t.Errorf("profile %q: expected %s in:\n%s", test.profile, test.href, svg)
+		}
+		if bytes.Contains(svg, []byte("xmlns:xlink=")) != test.xlink {
+			t.Errorf("profile %q: expected the xlink namespace to be declared: %v, in:\n%s", test.profile, test.xlink, svg)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "png2svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	svg, err := ConvertImage(mustReadImage(t, "img/glenda.png"), WithStrategy(BalancedStrategy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		output string
+		prefix []byte
+	}{
+		{"glenda.svg", svg},
+		{"glenda.svgz", []byte{0x1f, 0x8b}},
+		{"glenda.pdf", []byte("%PDF")},
+		{"glenda.eps", []byte("%!PS-Adobe")},
+	}
+	for _, test := range tests {
+		output := filepath.Join(dir, test.output)
+		if err := Convert("img/glenda.png", output, WithStrategy(BalancedStrategy), WithFileMode(0640)); err != nil {
+			t.Fatalf("%s: %v", test.output, err)
+		}
+		data, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, test.prefix) {
+			t.Errorf("%s: unexpected contents: %.40q", test.output, data)
+		}
+		if fi, err := os.Stat(output); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+			t.Errorf("%s: expected the permissions 640, got %o", test.output, fi.Mode().Perm())
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp")); len(matches) > 0 {
+		t.Errorf("temporary files were left behind: %v", matches)
+	}
+}
+
+// mustReadImage reads the given image, or fails the test
+func mustReadImage(t *testing.T, filename string) image.Image {
+	t.Helper()
+	img, err := ReadImage(filename, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestPremultipliedInput(t *testing.T) {
+	// The same semi-transparent orange, with and without premultiplied alpha, in 8 and 16 bits
+	premultiplied := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	premultiplied.SetRGBA(0, 0, color.RGBA{0x33, 0x11, 0, 0x33})
+	straight := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	straight.SetNRGBA(0, 0, color.NRGBA{0xff, 0x55, 0, 0x33})
+	premultiplied64 := image.NewRGBA64(image.Rect(0, 0, 1, 1))
+	premultiplied64.SetRGBA64(0, 0, color.RGBA64{0x3333, 0x1111, 0, 0x3333})
+	straight64 := image.NewNRGBA64(image.Rect(0, 0, 1, 1))
+	straight64.SetNRGBA64(0, 0, color.NRGBA64{0xffff, 0x5555, 0, 0x3333})
+	for i, img := range []image.Image{premultiplied, straight, premultiplied64, straight64} {
+		svg, err := ConvertImage(img, WithNamedColors(false))
+		if err != nil {
+			t.Fatalf("image %d: %v", i, err)
+		}
+		if !bytes.Contains(svg, []byte(`fill="#f50" fill-opacity="0.2"`)) {
+			t.Errorf("image %d: expected the straight color and the alpha value, in:\n%s", i, svg)
+		}
+	}
+}
+
+func TestXMLEncodingText(t *testing.T) {
+	img := uniformImage(2, 2, color.NRGBA{0xff, 0, 0, 0xff})
+	tests := []struct {
+		encoding string
+		title    string
+	}{
+		{"UTF-8", "<title>Glénda €</title>"},
+		{"", "<title>Glénda €</title>"},
+		{"ISO-8859-1", "<title>Gl&#233;nda &#8364;</title>"},
+		{"windows-1252", "<title>Gl&#233;nda &#8364;</title>"},
+	}
+	for _, test := range tests {
+		svg, err := ConvertImage(img, WithXMLEncoding(test.encoding), WithTitle("Glénda €"))
+		if err != nil {
+			t.Fatalf("encoding %q: %v", test.encoding, err)
+		}
+		if !bytes.Contains(svg, []byte(test.title)) {
+			t.Errorf("encoding %q: expected %s in:\n%s", test.encoding, test.title, svg)
+		}
+	}
+}
+
+func TestXMLProlog(t *testing.T) {
+	img := uniformImage(2, 2, color.NRGBA{0xff, 0, 0, 0xff})
+	tests := []struct {
+		encoding string
+		minify   bool
+		pretty   bool
+		prolog   string
+	}{
+		{"UTF-8", false, false, `<?xml version="1.0" encoding="UTF-8"?>`},
+		{"ISO-8859-1", false, false, `<?xml version="1.0" encoding="ISO-8859-1"?>`},
+		{"", false, false, ""},
+		{"UTF-8", true, false, ""},
+		{"ISO-8859-1", true, false, `<?xml version="1.0" encoding="ISO-8859-1"?>`},
+		{"", true, false, ""},
+		{"UTF-8", false, true, `<?xml version="1.0" encoding="UTF-8"?>` + "\n"},
+		{"ISO-8859-1", false, true, `<?xml version="1.0" encoding="ISO-8859-1"?>` + "\n"},
+		{"", false, true, ""},
+	}
+	for _, test := range tests {
+		svg, err := ConvertImage(img, WithXMLEncoding(test.encoding), WithMinify(test.minify), WithPretty(test.pretty))
+		if err != nil {
+			t.Fatalf("encoding %q: %v", test.encoding, err)
+		}
+		if !bytes.HasPrefix(svg, []byte(test.prolog+"<svg ")) {
+			t.Errorf("encoding %q, minify %v, pretty %v: expected the prolog %q, got:\n%s", test.encoding, test.minify, test.pretty, test.prolog, svg)
+		}
+	}
+}
+
+func TestSkipTransparent(t *testing.T) {
+	// Opaque pixels to the left, a semi-transparent column and fully transparent pixels to the right
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 60), 0x80, 0, 0xff})
+		}
+		img.SetNRGBA(4, y, color.NRGBA{0, 0, 0xff, 0x80})
+	}
+	tests := []struct {
+		name    string
+		options []Option
+	}{
+		{"default", nil},
+		{"pixels", []Option{WithSinglePixel(true)}},
+		{"rle", []Option{WithRuns(true)}},
+		{"optimal", []Option{WithOptimal(true)}},
+		{"quadtree", []Option{WithQuadtree(true)}},
+		{"largest", []Option{WithLargestFirst(true)}},
+		{"dominant", []Option{WithDominantColor(true)}},
+		{"background", []Option{WithBackground("#fff")}},
+		{"block", []Option{WithBlock(2, false)}},
+		{"majority", []Option{WithBlock(4, true)}},
+		{"colors", []Option{WithBackground("#fff"), WithColors(2)}},
+	}
+	for _, test := range tests {
+		o := NewOptions()
+		for _, option := range append(test.options, WithSkipTransparent(true)) {
+			option(o)
+		}
+		if err := o.Validate(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		pi := NewPixelImage(img, false)
+		pi.SetOptions(o)
+		pi.Cover(o.SinglePixelRectangles, false)
+		for _, rect := range pi.Rectangles() {
+			if rect.X+rect.W > 5 {
+				t.Errorf("%s: expected no rectangles over the transparent pixels, got %v", test.name, rect)
+			}
+		}
+		if len(pi.Rectangles()) == 0 {
+			t.Errorf("%s: expected rectangles for the pixels that are not transparent", test.name)
+		}
+	}
+	// Without SkipTransparent, the transparent pixels are blended with the background color
+	svg, err := ConvertImage(img, WithBackground("#fff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(svg, []byte(`<rect x="5" width="3" height="8" fill="#fff"/>`)) {
+		t.Errorf("expected a white rectangle over the transparent pixels, in:\n%s", svg)
+	}
+}
+
+func TestNamedColors(t *testing.T) {
+	tests := []struct {
+		c          color.NRGBA
+		named, hex string
+	}{
+		{color.NRGBA{0xff, 0, 0, 0xff}, `fill="red"`, `fill="#f00"`},
+		{color.NRGBA{0, 0, 0x80, 0xff}, `fill="navy"`, `fill="#000080"`},
+		// "aliceblue" is longer than the hex color, so the hex color is kept
+		{color.NRGBA{0xf0, 0xf8, 0xff, 0xff}, `fill="#f0f8ff"`, `fill="#f0f8ff"`},
+	}
+	for _, test := range tests {
+		img := uniformImage(2, 2, test.c)
+		svg, err := ConvertImage(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(svg, []byte(test.named)) {
+			t.Errorf("expected %s by default, in:\n%s", test.named, svg)
+		}
+		svg, err = ConvertImage(img, WithNamedColors(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(svg, []byte(test.hex)) {
+			t.Errorf("expected %s without named colors, in:\n%s", test.hex, svg)
+		}
+	}
+}
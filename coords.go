@@ -0,0 +1,120 @@
+package png2svg
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// coordinates converts pixel coordinates to the coordinates that are written to the SVG image,
+// by first scaling them and then moving them by an offset, and rounding them to the given precision
+type coordinates struct {
+	offsetX, offsetY float64
+	scale            float64
+	precision        int
+}
+
+// identity checks if the coordinates are written as they are
+func (c coordinates) identity() bool {
+	return c.offsetX == 0 && c.offsetY == 0 && c.scale == 1
+}
+
+// format formats the given number, rounded to the precision, without trailing zeros
+func (c coordinates) format(v float64) string {
+	p := math.Pow(10, float64(c.precision))
+	s := strconv.FormatFloat(math.Round(v*p)/p, 'f', -1, 64)
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// round rounds the given number to the precision
+func (c coordinates) round(v float64) float64 {
+	p := math.Pow(10, float64(c.precision))
+	return math.Round(v*p) / p
+}
+
+// xAt returns the given x coordinate, rounded to the precision
+func (c coordinates) xAt(x int) float64 {
+	return c.round(c.offsetX + float64(x)*c.scale)
+}
+
+// yAt returns the given y coordinate, rounded to the precision
+func (c coordinates) yAt(y int) float64 {
+	return c.round(c.offsetY + float64(y)*c.scale)
+}
+
+// x returns the given x coordinate
+func (c coordinates) x(x int) string {
+	return c.format(c.xAt(x))
+}
+
+// y returns the given y coordinate
+func (c coordinates) y(y int) string {
+	return c.format(c.yAt(y))
+}
+
+// dx returns the distance between the given x coordinates. The rounded coordinates are subtracted,
+// instead of rounding the scaled distance, so that the rounding errors do not add up along a path,
+// and so that touching rectangles still touch.
+func (c coordinates) dx(from, to int) string {
+	return c.format(c.xAt(to) - c.xAt(from))
+}
+
+// dy returns the distance between the given y coordinates
+func (c coordinates) dy(from, to int) string {
+	return c.format(c.yAt(to) - c.yAt(from))
+}
+
+// viewBox returns the viewBox of an image with the given width and height
+func (c coordinates) viewBox(w, h int) string {
+	return c.x(0) + " " + c.y(0) + " " + c.dx(0, w) + " " + c.dy(0, h)
+}
+
+// rectDocument creates a new SVG document with the rectangles that has been placed,
+// where the coordinates are scaled and moved. The rectangles are written as the content of
+// the <svg> tag, since adding many child tags one by one is slow.
+// If there is a gap, each rectangle is shrunk by half the gap on every side.
+func (pi *PixelImage) rectDocument() (*tinysvg.Document, *tinysvg.Tag) {
+	document, svgTag := tinysvg.NewTinySVG(pi.w, pi.h)
+	var (
+		buf  bytes.Buffer
+		c    = pi.coords
+		half = pi.gap * c.scale / 2
+	)
+	for _, r := range pi.rects {
+		if pi.gap > 0 {
+			x, y := c.xAt(r.X)+half, c.yAt(r.Y)+half
+			w, h := c.xAt(r.X+r.W)-c.xAt(r.X)-2*half, c.yAt(r.Y+r.H)-c.yAt(r.Y)-2*half
+			if c.round(w) <= 0 || c.round(h) <= 0 {
+				// The rectangle is smaller than the gap
+				continue
+			}
+			buf.WriteString(`<rect x="` + c.format(x))
+			buf.WriteString(`" y="` + c.format(y))
+			buf.WriteString(`" width="` + c.format(w))
+			buf.WriteString(`" height="` + c.format(h))
+		} else {
+			buf.WriteString(`<rect x="` + c.x(r.X))
+			buf.WriteString(`" y="` + c.y(r.Y))
+			buf.WriteString(`" width="` + c.dx(r.X, r.X+r.W))
+			buf.WriteString(`" height="` + c.dy(r.Y, r.Y+r.H))
+		}
+		if pi.rx > 0 {
+			buf.WriteString(`" rx="` + c.format(pi.rx*c.scale))
+		}
+		if pi.ry > 0 {
+			buf.WriteString(`" ry="` + c.format(pi.ry*c.scale))
+		}
+		buf.WriteString(`" fill="` + r.Fill)
+		if r.Alpha < 255 && !pi.hexAlpha {
+			buf.WriteString(`" fill-opacity="` + opacityString(r.Alpha))
+		}
+		buf.WriteString(`"/>`)
+	}
+	svgTag.AddContent(buf.Bytes())
+	return document, svgTag
+}
@@ -0,0 +1,78 @@
+package png2svg
+
+// paletteColors returns the colors that the pixels that are not transparent have, as r, g and b,
+// in the order that they first appear
+func (pi *PixelImage) paletteColors() [][3]int {
+	var (
+		palette [][3]int
+		seen    = make(map[[3]int]bool)
+	)
+	for _, p := range pi.pixels {
+		if c := [3]int{p.r, p.g, p.b}; p.a > 0 && !seen[c] {
+			seen[c] = true
+			palette = append(palette, c)
+		}
+	}
+	return palette
+}
+
+// rgb returns the r, g and b values of all pixels
+func (pi *PixelImage) rgb() [][3]int {
+	colors := make([][3]int, len(pi.pixels))
+	for i, p := range pi.pixels {
+		colors[i] = [3]int{p.r, p.g, p.b}
+	}
+	return colors
+}
+
+// setRGB sets the r, g and b values of all pixels to the given colors, from rgb
+func (pi *PixelImage) setRGB(colors [][3]int) {
+	for i, p := range pi.pixels {
+		p.r, p.g, p.b = colors[i][0], colors[i][1], colors[i][2]
+	}
+}
+
+// Dither gives each pixel the closest color in the palette, as r, g and b, like SnapToPalette,
+// but the difference between the color of the pixel and the palette color is spread to the pixels
+// to the right and below, with Floyd-Steinberg dithering. Gradients then look smooth from a distance,
+// even with few colors, but the image needs more rectangles. The alpha values are left as they are.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) Dither(palette [][3]int) {
+	if len(palette) == 0 {
+		return
+	}
+	// The errors that are spread to the current row and the next row, with one extra pixel on each side
+	current := make([][3]float64, pi.w+2)
+	next := make([][3]float64, pi.w+2)
+	for y := 0; y < pi.h; y++ {
+		for x := 0; x < pi.w; x++ {
+			p := pi.pixels[y*pi.w+x]
+			if p.a == 0 {
+				continue
+			}
+			// The color that the pixel should have had, to make up for the errors so far
+			var want [3]float64
+			for k, v := range [3]int{p.r, p.g, p.b} {
+				want[k] = float64(v) + current[x+1][k]
+				if want[k] < 0 {
+					want[k] = 0
+				} else if want[k] > 255 {
+					want[k] = 255
+				}
+			}
+			c := nearestColor(palette, int(want[0]+0.5), int(want[1]+0.5), int(want[2]+0.5))
+			for k := range want {
+				e := want[k] - float64(c[k])
+				current[x+2][k] += e * 7 / 16
+				next[x][k] += e * 3 / 16
+				next[x+1][k] += e * 5 / 16
+				next[x+2][k] += e / 16
+			}
+			p.r, p.g, p.b = c[0], c[1], c[2]
+		}
+		current, next = next, current
+		for i := range next {
+			next[i] = [3]float64{}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package png2svg
+
+import (
+	"bytes"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// luminance returns the relative luminance of the given color, from 0 for black to 1 for white
+func luminance(r, g, b int) float64 {
+	return (0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)) / 255
+}
+
+// dotDocument creates a new SVG document where each cell of the rectangles that has been placed
+// is drawn as a <circle> in the middle of the cell, with the color of the rectangle. If the dots
+// are scaled by luminance, dark cells get large dots and light cells get small dots, like a halftone print.
+func (pi *PixelImage) dotDocument() (*tinysvg.Document, *tinysvg.Tag) {
+	document, svgTag := tinysvg.NewTinySVG(pi.w, pi.h)
+	var (
+		buf  bytes.Buffer
+		c    = pi.coords
+		cell = pi.dotCell
+		cw   = (pi.w + cell - 1) / cell
+		done = make([]bool, cw*((pi.h+cell-1)/cell))
+	)
+	for _, r := range pi.rects {
+		// Go through the cells where the top left pixel is in this rectangle
+		for y := (r.Y + cell - 1) / cell * cell; y < r.Y+r.H; y += cell {
+			for x := (r.X + cell - 1) / cell * cell; x < r.X+r.W; x += cell {
+				if i := y/cell*cw + x/cell; done[i] {
+					continue
+				} else {
+					done[i] = true
+				}
+				radius := pi.dotRadius * float64(cell)
+				if pi.dotLuminance {
+					radius *= 1 - luminance(r.RGB())
+				}
+				if c.round(radius*c.scale) <= 0 {
+					continue
+				}
+				buf.WriteString(`<circle cx="` + c.format(c.offsetX+(float64(x)+float64(cell)/2)*c.scale))
+				buf.WriteString(`" cy="` + c.format(c.offsetY+(float64(y)+float64(cell)/2)*c.scale))
+				buf.WriteString(`" r="` + c.format(radius*c.scale))
+				buf.WriteString(`" fill="` + r.Fill)
+				if r.Alpha < 255 && !pi.hexAlpha {
+					buf.WriteString(`" fill-opacity="` + opacityString(r.Alpha))
+				}
+				buf.WriteString(`"/>`)
+			}
+		}
+	}
+	svgTag.AddContent(buf.Bytes())
+	return document, svgTag
+}
@@ -0,0 +1,40 @@
+package png2svg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// EPS returns the placed rectangles as an Encapsulated PostScript document, with the same
+// coordinates and fill colors as the SVG image. One pixel is one point (1/72 inch).
+// PostScript has no transparency, so semi-transparent rectangles are blended with white, like on paper.
+func (pi *PixelImage) EPS() ([]byte, error) {
+	if !pi.Done(0, 0) {
+		return nil, errors.New("the SVG representation does not cover all pixels")
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	fmt.Fprintf(&buf, "%%%%BoundingBox: 0 0 %d %d\n", pi.w, pi.h)
+	buf.WriteString("%%Creator: png2svg\n%%EndComments\n")
+	buf.WriteString("/R { rectfill } bind def\n")
+	buf.WriteString("gsave\n")
+	// Flip the coordinate system so that y grows downwards, like in SVG
+	fmt.Fprintf(&buf, "0 %d translate\n1 -1 scale\n", pi.h)
+	colors, groups := fillGroups(pi.rects)
+	for _, fill := range colors {
+		rects := groups[fill]
+		r, g, b := rects[0].RGB()
+		if a := rects[0].Alpha; a < 255 {
+			r = (r*a + 255*(255-a) + 127) / 255
+			g = (g*a + 255*(255-a) + 127) / 255
+			b = (b*a + 255*(255-a) + 127) / 255
+		}
+		fmt.Fprintf(&buf, "%s %s %s setrgbcolor\n", pdfNumber(r), pdfNumber(g), pdfNumber(b))
+		for _, rect := range rects {
+			fmt.Fprintf(&buf, "%d %d %d %d R\n", rect.X, rect.Y, rect.W, rect.H)
+		}
+	}
+	buf.WriteString("grestore\nshowpage\n%%EOF\n")
+	return buf.Bytes(), nil
+}
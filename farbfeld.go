@@ -0,0 +1,33 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+)
+
+// farbfeldMagic is the start of every farbfeld image
+var farbfeldMagic = []byte("farbfeld")
+
+// decodeFarbfeld decodes the given farbfeld image data.
+// The format is "farbfeld", followed by the width and height as 32-bit big endian integers,
+// followed by the pixels as 16-bit big endian R, G, B and A values, row by row.
+func decodeFarbfeld(data []byte) (image.Image, error) {
+	if !bytes.HasPrefix(data, farbfeldMagic) || len(data) < 16 {
+		return nil, errors.New("not a farbfeld image")
+	}
+	w := binary.BigEndian.Uint32(data[8:])
+	h := binary.BigEndian.Uint32(data[12:])
+	if w == 0 || h == 0 || w > 1<<15 || h > 1<<15 {
+		return nil, errors.New("invalid farbfeld image size")
+	}
+	pixels := data[16:]
+	if uint64(len(pixels)) < uint64(w)*uint64(h)*8 {
+		return nil, errors.New("the farbfeld image is truncated")
+	}
+	img := image.NewNRGBA64(image.Rect(0, 0, int(w), int(h)))
+	// The layout of image.NRGBA64 is the same as for farbfeld
+	copy(img.Pix, pixels)
+	return img, nil
+}
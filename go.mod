@@ -1,5 +1,8 @@
 module github.com/xyproto/png2svg
 
-require github.com/xyproto/tinysvg v1.1.0
+require (
+	github.com/xyproto/tinysvg v1.1.0
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
+)
 
 go 1.11
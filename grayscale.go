@@ -0,0 +1,71 @@
+package png2svg
+
+import (
+	"errors"
+	"strings"
+)
+
+// ParseGrayscaleWeights returns the weights of r, g and b for the given way of converting colors
+// to grayscale, which can be "rec709" (the default, for sRGB images), "rec601" (for video)
+// or "average" (the same weight for each channel). An empty string is the same as "rec709".
+func ParseGrayscaleWeights(name string) ([3]float64, error) {
+	switch strings.ToLower(name) {
+	case "", "rec709", "709":
+		return [3]float64{0.2126, 0.7152, 0.0722}, nil
+	case "rec601", "601":
+		return [3]float64{0.299, 0.587, 0.114}, nil
+	case "average":
+		return [3]float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, nil
+	}
+	return [3]float64{}, errors.New("unknown grayscale weights: " + name + " (must be rec709, rec601 or average)")
+}
+
+// Grayscale converts the colors of all pixels to gray, where the gray level is the sum of r, g and b,
+// multiplied by the given weights. Colors that only differ in hue are merged, so that fewer colors
+// and rectangles are needed. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) Grayscale(weights [3]float64) {
+	for _, p := range pi.pixels {
+		gray := int(weights[0]*float64(p.r) + weights[1]*float64(p.g) + weights[2]*float64(p.b) + 0.5)
+		if gray > 255 {
+			gray = 255
+		}
+		p.r, p.g, p.b = gray, gray, gray
+	}
+}
+
+// The colors of the ramp that sepia toning maps the gray levels onto, from dark brown to light cream
+const (
+	SepiaDark  = "#2b1d0e"
+	SepiaLight = "#f4e4c6"
+)
+
+// Duotone maps the gray level of each pixel onto a ramp between the dark and light color, as r, g and b,
+// where the gray level is found like by Grayscale. Black pixels get the dark color and white pixels get
+// the light color. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) Duotone(weights [3]float64, dark, light [3]int) {
+	for _, p := range pi.pixels {
+
+		gray := (weights[0]*float64(p.r) + weights[1]*float64(p.g) + weights[2]*float64(p.b)) / 255
+		if gray > 1 {
+			gray = 1
+		}
+		p.r = int(float64(dark[0]) + gray*float64(light[0]-dark[0]) + 0.5)
+		p.g = int(float64(dark[1]) + gray*float64(light[1]-dark[1]) + 0.5)
+		p.b = int(float64(dark[2]) + gray*float64(light[2]-dark[2]) + 0.5)
+	}
+}
+
+// Threshold gives each pixel the dark color if its gray level is below the given level, from 1 to 255,
+// and the light color if not, where the colors are r, g and b, and the gray level is found like by Grayscale.
+// This turns scanned line drawings and similar images into two colors. It must be called before the
+// pixels are covered with rectangles.
+func (pi *PixelImage) Threshold(weights [3]float64, level int, dark, light [3]int) {
+	for _, p := range pi.pixels {
+		gray := int(weights[0]*float64(p.r) + weights[1]*float64(p.g) + weights[2]*float64(p.b) + 0.5)
+		if gray < level {
+			p.r, p.g, p.b = dark[0], dark[1], dark[2]
+		} else {
+			p.r, p.g, p.b = light[0], light[1], light[2]
+		}
+	}
+}
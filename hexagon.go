@@ -0,0 +1,83 @@
+package png2svg
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// hexDocument creates a new SVG document where the image is drawn with pointy-top hexagons, instead of
+// rectangles. The hexagons have the given radius, in pixels, and every other row is moved half a hexagon
+// to the right. Each hexagon gets the color of the pixel at its center. Transparent pixels give no hexagon.
+func (pi *PixelImage) hexDocument() (*tinysvg.Document, *tinysvg.Tag) {
+	document, svgTag := tinysvg.NewTinySVG(pi.w, pi.h)
+	var (
+		buf    bytes.Buffer
+		c      = pi.coords
+		radius = pi.hexRadius
+		width  = math.Sqrt(3) * radius
+	)
+	// The corners of a hexagon, relative to its center, clockwise from the top
+	corners := [6][2]float64{
+		{0, -radius},
+		{width / 2, -radius / 2},
+		{width / 2, radius / 2},
+		{0, radius},
+		{-width / 2, radius / 2},
+		{-width / 2, -radius / 2},
+	}
+	for row := 0; float64(row)*1.5*radius-radius < float64(pi.h); row++ {
+		cy := float64(row) * 1.5 * radius
+		for col := 0; float64(col)*width-width < float64(pi.w); col++ {
+			cx := float64(col) * width
+			if row%2 == 1 {
+				cx += width / 2
+			}
+			// Sample the pixel at the center, or the closest pixel at the edges of the image
+			x := int(math.Min(math.Max(cx, 0), float64(pi.w-1)))
+			y := int(math.Min(math.Max(cy, 0), float64(pi.h-1)))
+			p := pi.pixels[y*pi.w+x]
+			if p.a == 0 {
+				continue
+			}
+			var colorString string
+			if pi.colorOptimize {
+				colorString = shortColorString(p.r, p.g, p.b)
+			} else {
+				colorString = string(hexColorBytes(p.r, p.g, p.b))
+			}
+			if p.a < 255 && pi.hexAlpha {
+				colorString = withAlpha(colorString, p.a)
+			}
+
+			// Draw the hexagon with relative lines between the rounded corners
+			lastx, lasty := c.round(c.offsetX+(cx+corners[0][0])*c.scale), c.round(c.offsetY+(cy+corners[0][1])*c.scale)
+			buf.WriteString(`<path d="M` + c.format(lastx) + " " + c.format(lasty))
+			for _, corner := range corners[1:5] {
+				nx, ny := c.round(c.offsetX+(cx+corner[0])*c.scale), c.round(c.offsetY+(cy+corner[1])*c.scale)
+				switch {
+				case nx == lastx:
+					buf.WriteString("v" + c.format(ny-lasty))
+				default:
+					buf.WriteString("l" + c.format(nx-lastx))
+					if s := c.format(ny - lasty); s[0] == '-' {
+						buf.WriteString(s)
+					} else {
+						buf.WriteString(" " + s)
+					}
+				}
+				lastx, lasty = nx, ny
+			}
+			// The last corner is straight above the one before, and the last side is drawn by closing the path
+			ny := c.round(c.offsetY + (cy+corners[5][1])*c.scale)
+			buf.WriteString("v" + c.format(ny-lasty) + `z" fill="` + colorString)
+			if p.a < 255 && !pi.hexAlpha {
+				buf.WriteString(`" fill-opacity="` + opacityString(p.a))
+			}
+			buf.WriteString(`"/>`)
+		}
+	}
+	svgTag.AddContent(buf.Bytes())
+	return document, svgTag
+}
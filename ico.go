@@ -0,0 +1,212 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// icoEntry is an entry in the directory of an ICO file
+type icoEntry struct {
+	width, height int
+	bitCount      int
+	data          []byte
+}
+
+// isICO checks if the given data starts with the header of an icon (ICO) or cursor (CUR) file
+func isICO(data []byte) bool {
+	return len(data) >= 6 && bytes.HasPrefix(data, []byte{0, 0}) && (data[2] == 1 || data[2] == 2) && data[3] == 0
+}
+
+// icoEntries returns the images in the given ICO data
+func icoEntries(data []byte) ([]icoEntry, error) {
+	if !isICO(data) {
+		return nil, errors.New("not an ICO image")
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	var entries []icoEntry
+	for i := 0; i < count; i++ {
+		pos := 6 + i*16
+		if pos+16 > len(data) {
+			return nil, errors.New("the ICO directory is truncated")
+		}
+		e := data[pos : pos+16]
+		size := int(binary.LittleEndian.Uint32(e[8:]))
+		offset := int(binary.LittleEndian.Uint32(e[12:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, errors.New("an ICO image entry is out of bounds")
+		}
+		entry := icoEntry{
+			width:    int(e[0]),
+			height:   int(e[1]),
+			bitCount: int(binary.LittleEndian.Uint16(e[6:])),
+			data:     data[offset : offset+size],
+		}
+		// A width or height of 0 means 256
+		if entry.width == 0 {
+			entry.width = 256
+		}
+		if entry.height == 0 {
+			entry.height = 256
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("the ICO image contains no images")
+	}
+	return entries, nil
+}
+
+// decodeICO decodes the image with the given width from the given ICO data.
+// If size is 0, the largest image is used, preferring the one with the most colors.
+func decodeICO(data []byte, size int) (image.Image, error) {
+	entries, err := icoEntries(data)
+	if err != nil {
+		return nil, err
+	}
+	var selected *icoEntry
+	for i := range entries {
+		e := &entries[i]
+		if size > 0 {
+			if e.width == size && (selected == nil || e.bitCount > selected.bitCount) {
+				selected = e
+			}
+			continue
+		}
+		if selected == nil || e.width*e.height > selected.width*selected.height ||
+			(e.width*e.height == selected.width*selected.height && e.bitCount > selected.bitCount) {
+			selected = e
+		}
+	}
+	if selected == nil {
+		var sizes []string
+		for _, e := range entries {
+			sizes = append(sizes, fmt.Sprintf("%dx%d", e.width, e.height))
+		}
+		sort.Strings(sizes)
+		return nil, fmt.Errorf("the ICO image has no %dx%d image, only %s", size, size, strings.Join(sizes, ", "))
+	}
+	// Newer ICO files may contain PNG images
+	if bytes.HasPrefix(selected.data, pngSignature) {
+		return decodePNG(selected.data, false)
+	}
+	return decodeDIB(selected.data)
+}
+
+// decodeDIB decodes a device independent bitmap from an ICO file, which is a BMP image
+// without the file header, with twice the height, since it is followed by a transparency mask
+func decodeDIB(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errors.New("the ICO bitmap header is truncated")
+	}
+	var (
+		headerSize = int(binary.LittleEndian.Uint32(data[0:]))
+		w          = int(int32(binary.LittleEndian.Uint32(data[4:])))
+		h          = int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2
+		bitCount   = int(binary.LittleEndian.Uint16(data[14:]))
+		colorsUsed = int(binary.LittleEndian.Uint32(data[32:]))
+	)
+	if h < 0 {
+		h = -h
+	}
+	if w <= 0 || h <= 0 || w > 1024 || h > 1024 || headerSize < 40 || headerSize > len(data) {
+		return nil, errors.New("invalid ICO bitmap header")
+	}
+
+	// Read the palette, for images with 8 bits per pixel or less
+	pos := headerSize
+	var palette []color.NRGBA
+	switch bitCount {
+	case 1, 4, 8:
+		if colorsUsed == 0 {
+			colorsUsed = 1 << uint(bitCount)
+		}
+		if pos+colorsUsed*4 > len(data) {
+			return nil, errors.New("the ICO bitmap palette is truncated")
+		}
+		for i := 0; i < colorsUsed; i++ {
+			p := data[pos+i*4:]
+			palette = append(palette, color.NRGBA{p[2], p[1], p[0], 0xff})
+		}
+		pos += colorsUsed * 4
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("unsupported number of bits per pixel in ICO bitmap: %d", bitCount)
+	}
+
+	// The rows are stored bottom-up, and each row is padded to a multiple of 4 bytes
+	stride := ((w*bitCount + 31) / 32) * 4
+	maskStride := ((w + 31) / 32) * 4
+	if pos+stride*h > len(data) {
+		return nil, errors.New("the ICO bitmap is truncated")
+	}
+	mask := data[pos+stride*h:]
+	hasMask := len(mask) >= maskStride*h
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	hasAlpha := false
+	for y := 0; y < h; y++ {
+		row := data[pos+(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{row[x*4+2], row[x*4+1], row[x*4], row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{row[x*3+2], row[x*3+1], row[x*3], 0xff}
+			default:
+				bit := x * bitCount
+				index := int(row[bit/8]>>uint(8-bitCount-bit%8)) & (1<<uint(bitCount) - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// The transparency mask is used if there is no alpha channel, or if the alpha channel is empty
+	if (bitCount != 32 || !hasAlpha) && hasMask {
+		for y := 0; y < h; y++ {
+			row := mask[(h-1-y)*maskStride:]
+			for x := 0; x < w; x++ {
+				i := img.PixOffset(x, y)
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					img.Pix[i+3] = 0
+				} else {
+					img.Pix[i+3] = 0xff
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// ReadICO reads the image with the given width from the given ICO filename.
+// If size is 0, the largest image in the ICO file is used.
+// If verbose is true, some basic information is printed to stdout.
+func ReadICO(filename string, size int, verbose bool) (image.Image, error) {
+	if verbose {
+		fmt.Printf("Reading %s", filename)
+		defer fmt.Println()
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeICO(data, size)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf(" (%dx%d)", img.Bounds().Max.X-img.Bounds().Min.X, img.Bounds().Max.Y-img.Bounds().Min.Y)
+	}
+	return img, nil
+}
@@ -0,0 +1,65 @@
+package png2svg
+
+// KMeans reduces the image to at most n colors, with k-means quantization. The colors from MedianCut
+// are improved by giving each color of the image the nearest of the n colors, and then moving each of
+// the n colors to the average of the colors that were given it, for at most the given number of
+// iterations, or until no color is given another one. This is much slower than MedianCut, but gives
+// colors that are closer to the image. Transparent pixels are left as they are.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) KMeans(n, iterations int) {
+	if n < 1 {
+		return
+	}
+	colors := pi.colorCounts()
+	if len(colors) <= n {
+		return
+	}
+	clusters := medianCut(colors, n)
+	centers := make([][4]int, len(clusters))
+	for i, cluster := range clusters {
+		centers[i] = averageColor(cluster)
+	}
+	// The index of the cluster that each color belongs to, or -1 before the first iteration
+	nearest := make([]int, len(colors))
+	for i := range nearest {
+		nearest[i] = -1
+	}
+	for iteration := 0; iteration < iterations; iteration++ {
+		changed := false
+		for i, cc := range colors {
+			best, bestDistance := 0, -1
+			for j, center := range centers {
+				distance := 0
+				for k := range center {
+					d := cc.c[k] - center[k]
+					distance += d * d
+				}
+				if bestDistance < 0 || distance < bestDistance {
+					best, bestDistance = j, distance
+				}
+			}
+			if nearest[i] != best {
+				nearest[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		// Move the centers, and leave out the clusters that no colors belong to
+		clusters = make([][]colorCount, len(centers))
+		for i, cc := range colors {
+			clusters[nearest[i]] = append(clusters[nearest[i]], cc)
+		}
+		centers = centers[:0]
+		kept := clusters[:0]
+		for _, cluster := range clusters {
+			if len(cluster) > 0 {
+				centers = append(centers, averageColor(cluster))
+				kept = append(kept, cluster)
+			}
+		}
+		clusters = kept
+	}
+	pi.replaceColors(clusters)
+}
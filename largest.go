@@ -0,0 +1,162 @@
+package png2svg
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// scoredBox is a box, together with the number of uncovered pixels that it would cover
+type scoredBox struct {
+	Box
+	score int
+}
+
+// boxHeap is a priority queue of boxes, where the box that would cover the most uncovered pixels
+// comes first, and boxes with the same score come in the order of their top left corner
+type boxHeap []scoredBox
+
+func (bh boxHeap) Len() int { return len(bh) }
+
+func (bh boxHeap) Less(i, j int) bool {
+	a, b := bh[i], bh[j]
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if a.y != b.y {
+		return a.y < b.y
+	}
+	return a.x < b.x
+}
+
+func (bh boxHeap) Swap(i, j int) { bh[i], bh[j] = bh[j], bh[i] }
+
+func (bh *boxHeap) Push(x interface{}) { *bh = append(*bh, x.(scoredBox)) }
+
+func (bh *boxHeap) Pop() interface{} {
+	old := *bh
+	box := old[len(old)-1]
+	*bh = old[:len(old)-1]
+	return box
+}
+
+// CoverLargestFirst will cover all pixels that are not yet covered by an SVG element, by repeatedly
+// placing the rectangle of one color that covers the most uncovered pixels, instead of expanding
+// rectangles from the top left. Like when expanding rectangles, opaque rectangles may overlap
+// rectangles of the same color that has already been placed.
+func (pi *PixelImage) CoverLargestFirst() {
+	var candidates boxHeap
+	for {
+		// Start with the largest rectangles that end at each row
+		pi.maximalBoxes(0, 0, pi.w, pi.h, func(box Box) {
+			candidates = append(candidates, scoredBox{box, box.w * box.h})
+		})
+		if len(candidates) == 0 {
+			break
+		}
+		heap.Init(&candidates)
+
+		for candidates.Len() > 0 {
+			candidate := heap.Pop(&candidates).(scoredBox)
+			box := candidate.Box
+			uncovered := pi.uncoveredCount(box)
+			switch {
+			case uncovered == candidate.score:
+				pi.CoverBox(&box, false, pi.colorOptimize)
+			case uncovered == 0:
+			case box.a == 255:
+				// Some of the pixels have been covered, so the box is worth less than it was
+				heap.Push(&candidates, scoredBox{box, uncovered})
+			default:
+				// Semi-transparent rectangles can not overlap, so try again with the largest
+				// rectangle that is left. Any other pixels that are left are found by the next round.
+				var largest Box
+				pi.maximalBoxes(box.x, box.y, box.w, box.h, func(inside Box) {
+					if inside.w*inside.h > largest.w*largest.h {
+						largest = inside
+					}
+				})
+				heap.Push(&candidates, scoredBox{largest, largest.w * largest.h})
+			}
+		}
+	}
+	if pi.verbose {
+		fmt.Printf("Covered the pixels with %d rectangles, largest first.\n", len(pi.rects))
+	}
+}
+
+// uncoveredCount returns the number of pixels in the given box that are not covered
+func (pi *PixelImage) uncoveredCount(box Box) int {
+	count := 0
+	for y := box.y; y < box.y+box.h; y++ {
+		for x := box.x; x < box.x+box.w; x++ {
+			if !pi.pixels[y*pi.w+x].covered {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// maximalBoxes finds the boxes of one color that only have uncovered pixels, within the given area,
+// and that can not be made wider or taller upwards. For each row, the uncovered pixels above
+// each pixel that have the same color are counted, and the boxes are found with a stack, like
+// when searching for the largest rectangle under a histogram. The boxes are passed to the given function.
+func (pi *PixelImage) maximalBoxes(x0, y0, w, h int, found func(Box)) {
+	heights := make([]int, w)
+	var stack []int
+	for y := y0; y < y0+h; y++ {
+		for i := range heights {
+			p := pi.pixels[y*pi.w+x0+i]
+			switch {
+			case p.covered:
+				heights[i] = 0
+			case heights[i] > 0:
+				if q := pi.pixels[(y-1)*pi.w+x0+i]; q.r == p.r && q.g == p.g && q.b == p.b && q.a == p.a {
+					heights[i]++
+				} else {
+					heights[i] = 1
+				}
+			default:
+				heights[i] = 1
+			}
+		}
+		// Go through each run of uncovered pixels with the same color
+		for start := 0; start < w; {
+			p := pi.pixels[y*pi.w+x0+start]
+			if p.covered {
+				start++
+				continue
+			}
+			end := start + 1
+			for end < w {
+				q := pi.pixels[y*pi.w+x0+end]
+				if q.covered || q.r != p.r || q.g != p.g || q.b != p.b || q.a != p.a {
+					break
+				}
+				end++
+			}
+			stack = stack[:0]
+			for i := start; i <= end; i++ {
+				height := 0
+				if i < end {
+					height = heights[i]
+				}
+				for len(stack) > 0 && heights[stack[len(stack)-1]] >= height {
+					top := heights[stack[len(stack)-1]]
+					stack = stack[:len(stack)-1]
+					if top == height {
+						// The same box is found by the next column
+						continue
+					}
+					left := start
+					if len(stack) > 0 {
+						left = stack[len(stack)-1] + 1
+					}
+					found(Box{x0 + left, y - top + 1, i - left, top, p.r, p.g, p.b, p.a})
+				}
+				stack = append(stack, i)
+			}
+			start = end
+		}
+	}
+}
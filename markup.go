@@ -0,0 +1,402 @@
+package png2svg
+
+import (
+	"bytes"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// formatNumber formats the given number with at most 3 decimals and no trailing zeros
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(math.Round(x*1000)/1000, 'f', -1, 64)
+}
+
+// insertID gives the element that starts the given line the given id, as the first attribute
+func insertID(line []byte, id string) []byte {
+	start := bytes.IndexByte(line, '<')
+	if start == -1 {
+		return line
+	}
+	pos := bytes.IndexByte(line[start:], ' ')
+	if pos == -1 {
+		return line
+	}
+	pos += start
+	result := make([]byte, 0, len(line)+len(id)+6)
+	result = append(result, line[:pos]...)
+	result = append(result, ` id="`...)
+	result = append(result, id...)
+	result = append(result, '"')
+	result = append(result, line[pos:]...)
+	return result
+}
+
+// insertAfterSVGTag inserts the given markup right after the opening <svg> tag
+func insertAfterSVGTag(svgDocument, markup []byte) []byte {
+	start := bytes.Index(svgDocument, []byte("<svg"))
+	if start == -1 {
+		return svgDocument
+	}
+	end := bytes.IndexByte(svgDocument[start:], '>')
+	if end == -1 {
+		return svgDocument
+	}
+	pos := start + end + 1
+	result := make([]byte, 0, len(svgDocument)+len(markup))
+	result = append(result, svgDocument[:pos]...)
+	result = append(result, markup...)
+	return append(result, svgDocument[pos:]...)
+}
+
+// checkerMarkup returns a pattern definition and a backing rectangle that
+// draws a subtle checkerboard for an image of the given width and height.
+// Note that patterns are not a part of SVG Tiny 1.2, but browsers support them.
+func checkerMarkup(w, h int) []byte {
+	// Aim for 16 squares along the shortest side
+	size := w
+	if h < size {
+		size = h
+	}
+	size /= 16
+	if size < 1 {
+		size = 1
+	}
+	s := strconv.Itoa(size)
+	s2 := strconv.Itoa(size * 2)
+	var buf bytes.Buffer
+	buf.WriteString(`<defs><pattern id="checker" width="` + s2 + `" height="` + s2 + `" patternUnits="userSpaceOnUse">`)
+	buf.WriteString(`<rect width="` + s2 + `" height="` + s2 + `" fill="#fff"/>`)
+	buf.WriteString(`<rect width="` + s + `" height="` + s + `" fill="#ddd"/>`)
+	buf.WriteString(`<rect x="` + s + `" y="` + s + `" width="` + s + `" height="` + s + `" fill="#ddd"/>`)
+	buf.WriteString(`</pattern></defs>`)
+	buf.WriteString(`<rect width="` + strconv.Itoa(w) + `" height="` + strconv.Itoa(h) + `" fill="url(#checker)"/>`)
+	return buf.Bytes()
+}
+
+// titleMarkup returns a <title> and a <desc> element with the given text, if it is not empty
+func titleMarkup(title, description string) []byte {
+	var buf bytes.Buffer
+	if title != "" {
+		buf.WriteString("<title>" + html.EscapeString(title) + "</title>")
+	}
+	if description != "" {
+		buf.WriteString("<desc>" + html.EscapeString(description) + "</desc>")
+	}
+	return buf.Bytes()
+}
+
+// metadataMarkup returns a <metadata> element with the png2svg version,
+// the given source filename and flags and the given number of rectangles
+func metadataMarkup(source, flags string, rectangles int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<metadata><png2svg xmlns="https://github.com/xyproto/png2svg"`)
+	buf.WriteString(` version="` + html.EscapeString(VersionString) + `"`)
+	if source != "" {
+		buf.WriteString(` source="` + html.EscapeString(source) + `"`)
+	}
+	if flags != "" {
+		buf.WriteString(` flags="` + html.EscapeString(flags) + `"`)
+	}
+	buf.WriteString(` rectangles="` + strconv.Itoa(rectangles) + `"/></metadata>`)
+	return buf.Bytes()
+}
+
+// indentMarkup places each tag of the given SVG document on a separate line,
+// indented by two spaces for each level, which makes it easier to read and diff.
+// Elements with text, like <title>, are kept on one line, together with the closing tag.
+func indentMarkup(svgDocument []byte) []byte {
+	var buf bytes.Buffer
+	level := 0
+	tags := bytes.Split(svgDocument, []byte("<"))
+	for i := 0; i < len(tags); i++ {
+		tag := bytes.TrimSpace(tags[i])
+		if len(tag) == 0 {
+			continue
+		}
+		closing := tag[0] == '/'
+		if closing && level > 0 {
+			level--
+		}
+		buf.Write(bytes.Repeat([]byte("  "), level))
+		buf.WriteByte('<')
+		if !closing && !bytes.HasSuffix(tag, []byte(">")) && i+1 < len(tags) && bytes.HasPrefix(tags[i+1], []byte("/")) {
+			// An element with text
+			buf.Write(tags[i])
+			buf.WriteByte('<')
+			buf.Write(bytes.TrimSpace(tags[i+1]))
+			buf.WriteByte('\n')
+			i++
+			continue
+		}
+		buf.Write(tag)
+		buf.WriteByte('\n')
+		if !closing && tag[0] != '?' && tag[0] != '!' && !bytes.HasSuffix(tag, []byte("/>")) {
+			level++
+		}
+	}
+	return buf.Bytes()
+}
+
+// asciiMarkup replaces the characters in the given UTF-8 markup that are not ASCII with
+// numeric character references, like "&#233;" for "é", so that the markup is the same
+// in any encoding that is a superset of ASCII, like ISO-8859-1.
+func asciiMarkup(svgDocument []byte) []byte {
+	if isASCII(svgDocument) {
+		return svgDocument
+	}
+	var buf bytes.Buffer
+	for len(svgDocument) > 0 {
+		r, size := utf8.DecodeRune(svgDocument)
+		if r < utf8.RuneSelf {
+			buf.WriteByte(svgDocument[0])
+		} else {
+			buf.WriteString("&#" + strconv.Itoa(int(r)) + ";")
+		}
+		svgDocument = svgDocument[size:]
+	}
+	return buf.Bytes()
+}
+
+// isASCII checks if all bytes in the given data are ASCII
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// minifyMarkup removes the parts of the given SVG document that are optional:
+// the XML prolog (UTF-8 is the default encoding), the version and baseProfile
+// attributes and the "px" unit of the width and height of the <svg> tag.
+func minifyMarkup(svgDocument []byte) []byte {
+	svgDocument = bytes.TrimPrefix(svgDocument, []byte(`<?xml version="1.0" encoding="UTF-8"?>`))
+	return editSVGTag(svgDocument, func(svgTag []byte) []byte {
+		svgTag = bytes.Replace(svgTag, []byte(` version="1.2"`), []byte{}, 1)
+		svgTag = bytes.Replace(svgTag, []byte(` baseProfile="tiny"`), []byte{}, 1)
+		return bytes.Replace(svgTag, []byte(`px"`), []byte(`"`), -1)
+	})
+}
+
+// profileMarkup changes the version and baseProfile attributes of the given SVG document,
+// which are for SVG Tiny 1.2, to the ones of the given SVG profile: "1.1" or "2"
+func profileMarkup(svgDocument []byte, profile string) []byte {
+	return editSVGTag(svgDocument, func(svgTag []byte) []byte {
+		switch profile {
+		case "1.1":
+			svgTag = bytes.Replace(svgTag, []byte(` version="1.2"`), []byte(` version="1.1"`), 1)
+		case "2":
+			// SVG 2 has no version attribute
+			svgTag = bytes.Replace(svgTag, []byte(` version="1.2"`), []byte{}, 1)
+		default:
+			return svgTag
+		}
+		return bytes.Replace(svgTag, []byte(` baseProfile="tiny"`), []byte{}, 1)
+	})
+}
+
+// editSVGTag replaces the opening <svg> tag of the given SVG document with the result of the given function
+func editSVGTag(svgDocument []byte, edit func(svgTag []byte) []byte) []byte {
+	start := bytes.Index(svgDocument, []byte("<svg"))
+	if start == -1 {
+		return svgDocument
+	}
+	end := bytes.IndexByte(svgDocument[start:], '>')
+	if end == -1 {
+		return svgDocument
+	}
+	svgTag := make([]byte, end)
+	copy(svgTag, svgDocument[start:start+end])
+	edited := edit(svgTag)
+	result := make([]byte, 0, len(svgDocument))
+	result = append(result, svgDocument[:start]...)
+	result = append(result, edited...)
+	return append(result, svgDocument[start+end:]...)
+}
+
+// attributeOrder is the order that attributes are written in, so that the output is
+// the same every time. Other attributes are placed after these, in alphabetical order.
+var attributeOrder = []string{"xmlns", "xmlns:inkscape", "xmlns:xlink", "version", "baseProfile", "viewBox", "id", "x", "y", "width", "height", "rx", "ry", "fill"}
+
+// attributeRank returns the position of the given attribute name in attributeOrder
+func attributeRank(name string) int {
+	for i, attributeName := range attributeOrder {
+		if name == attributeName {
+			return i
+		}
+	}
+	return len(attributeOrder)
+}
+
+// orderAttributes sorts the attributes of the given tag, which is missing the final ">",
+// like `<rect width="1" x="2" fill="#fff" /`. The tinysvg package stores attributes in
+// a map, which would otherwise give a different attribute order every time.
+// The tag is returned as it is if it is not on the expected form.
+func orderAttributes(tag []byte) []byte {
+	start := bytes.IndexByte(tag, '<')
+	if start == -1 || start+1 >= len(tag) || tag[start+1] == '?' || tag[start+1] == '/' || tag[start+1] == '!' {
+		return tag
+	}
+	nameEnd := bytes.IndexByte(tag[start:], ' ')
+	if nameEnd == -1 {
+		return tag
+	}
+	nameEnd += start
+	rest := bytes.TrimSpace(tag[nameEnd:])
+	selfClosing := bytes.HasSuffix(rest, []byte("/"))
+	rest = bytes.TrimSpace(bytes.TrimSuffix(rest, []byte("/")))
+
+	type attribute struct {
+		name, value []byte
+	}
+	var attributes []attribute
+	for len(rest) > 0 {
+		eq := bytes.Index(rest, []byte(`="`))
+		if eq == -1 {
+			return tag
+		}
+		end := bytes.IndexByte(rest[eq+2:], '"')
+		if end == -1 {
+			return tag
+		}
+		attributes = append(attributes, attribute{bytes.TrimSpace(rest[:eq]), rest[eq+2 : eq+2+end]})
+		rest = bytes.TrimSpace(rest[eq+2+end+1:])
+	}
+	sort.SliceStable(attributes, func(i, j int) bool {
+		ri, rj := attributeRank(string(attributes[i].name)), attributeRank(string(attributes[j].name))
+		if ri != rj {
+			return ri < rj
+		}
+		return bytes.Compare(attributes[i].name, attributes[j].name) < 0
+	})
+
+	result := make([]byte, 0, len(tag))
+	result = append(result, tag[:nameEnd]...)
+	for _, a := range attributes {
+		result = append(result, ' ')
+		result = append(result, a.name...)
+		result = append(result, '=', '"')
+		result = append(result, a.value...)
+		result = append(result, '"')
+	}
+	if selfClosing {
+		result = append(result, ' ', '/')
+	}
+	return result
+}
+
+// classMarkup replaces the fill attributes of the given SVG document with class attributes,
+// and inserts a <style> block with one class per fill color, in the order they first appear.
+// This makes it possible to recolor the image by editing the style block only.
+// Note that style sheets are not a part of SVG Tiny 1.2, but browsers and editors support them.
+func classMarkup(svgDocument []byte) []byte {
+	var (
+		buf     bytes.Buffer
+		style   bytes.Buffer
+		classes = make(map[string]string)
+		fill    = []byte(` fill="`)
+	)
+	style.WriteString("<style>")
+	for {
+		start := bytes.Index(svgDocument, fill)
+		if start == -1 {
+			break
+		}
+		end := bytes.IndexByte(svgDocument[start+len(fill):], '"')
+		if end == -1 {
+			break
+		}
+		color := string(svgDocument[start+len(fill) : start+len(fill)+end])
+		class, ok := classes[color]
+		if !ok {
+			class = "c" + strconv.Itoa(len(classes))
+			classes[color] = class
+			style.WriteString("." + class + "{fill:" + color + "}")
+		}
+		buf.Write(svgDocument[:start])
+		buf.WriteString(` class="` + class + `"`)
+		svgDocument = svgDocument[start+len(fill)+end+1:]
+	}
+	buf.Write(svgDocument)
+	if len(classes) == 0 {
+		return buf.Bytes()
+	}
+	style.WriteString("</style>")
+	return insertAfterSVGTag(buf.Bytes(), style.Bytes())
+}
+
+// minUses is how many rectangles that must have the same size before they are drawn with <use>
+const minUses = 3
+
+// rectSize returns the width and height attributes of the given <rect> line, which
+// follow each other since the attributes are ordered, or nil if it is not a <rect>
+func rectSize(line []byte) []byte {
+	if !bytes.HasPrefix(line, []byte("<rect ")) {
+		return nil
+	}
+	start := bytes.Index(line, []byte(` width="`))
+	if start == -1 {
+		return nil
+	}
+	height := bytes.Index(line[start:], []byte(` height="`))
+	if height == -1 {
+		return nil
+	}
+	end := bytes.IndexByte(line[start+height+len(` height="`):], '"')
+	if end == -1 {
+		return nil
+	}
+	end += start + height + len(` height="`) + 1
+	// Rounded corners are also a part of the shape
+	for _, name := range []string{` rx="`, ` ry="`} {
+		if bytes.HasPrefix(line[end:], []byte(name)) {
+			if valueEnd := bytes.IndexByte(line[end+len(name):], '"'); valueEnd != -1 {
+				end += len(name) + valueEnd + 1
+			}
+		}
+	}
+	return line[start:end]
+}
+
+// useLines replaces the <rect> lines that have the same width and height as at least
+// minUses-1 other rectangles with <use> lines, that refer to one rectangle of that size.
+// The rectangles that are referred to are returned as a <defs> block, or nil if there are none.
+// The use lines refer to the rectangles with the given attribute, like "href" or "xlink:href".
+func useLines(lines [][]byte, href string) ([][]byte, []byte) {
+	counts := make(map[string]int)
+	for _, line := range lines {
+		if size := rectSize(line); size != nil {
+			counts[string(size)]++
+		}
+	}
+	var (
+		defs bytes.Buffer
+		ids  = make(map[string]string)
+	)
+	for i, line := range lines {
+		size := rectSize(line)
+		if size == nil || counts[string(size)] < minUses {
+			continue
+		}
+		id, ok := ids[string(size)]
+		if !ok {
+			id = "r" + strconv.FormatInt(int64(len(ids)), 36)
+			ids[string(size)] = id
+			defs.WriteString(`<rect id="` + id + `"`)
+			defs.Write(size)
+			defs.WriteString("/>")
+		}
+		var buf bytes.Buffer
+		buf.WriteString(`<use ` + href + `="#` + id + `"`)
+		buf.Write(bytes.Replace(line[len("<rect"):], size, []byte{}, 1))
+		lines[i] = buf.Bytes()
+	}
+	if len(ids) == 0 {
+		return lines, nil
+	}
+	return lines, append(append([]byte("<defs>"), defs.Bytes()...), "</defs>"...)
+}
@@ -0,0 +1,175 @@
+package png2svg
+
+import "sort"
+
+const (
+	// octreeDepth is the number of bits per channel, which is also the depth of the leaves of a full octree
+	octreeDepth = 8
+	// octreeLeaves is the smallest number of leaves that the tree is allowed to have while the pixels are added
+	octreeLeaves = 1024
+)
+
+// octreeNode is a node in the tree that is used by Octree. Each level of the tree uses one more bit of
+// each channel, so a node has up to 16 children, since the alpha value is used together with r, g and b.
+// Each node holds the number of pixels below it, and a leaf also holds the sum of their colors.
+type octreeNode struct {
+	children [16]*octreeNode
+	sum      [4]int
+	count    int
+	leaf     bool
+}
+
+// octreeChild returns the index of the child that the given color belongs to, at the given level
+func octreeChild(c [4]int, level int) int {
+	shift := uint(octreeDepth - 1 - level)
+	return (c[0]>>shift&1)<<3 | (c[1]>>shift&1)<<2 | (c[2]>>shift&1)<<1 | c[3]>>shift&1
+}
+
+// Octree reduces the image to at most n colors, with octree quantization. The colors of the pixels are
+// added to a tree, one bit per channel and level, and whenever there are too many leaves, the leaves
+// of the deepest node with the fewest pixels are merged into one, or just the two smallest of them,
+// if merging all of them would give too few colors. The colors of each leaf are replaced by their average.
+// This needs less memory than MedianCut for large images with many colors, since the size of the tree
+// is limited. Transparent pixels are left as they are. It must be called before the pixels are covered
+// with rectangles.
+func (pi *PixelImage) Octree(n int) {
+	if n < 1 {
+		return
+	}
+	var (
+		root   = &octreeNode{}
+		leaves int
+		// The nodes that have children, for each level
+		reducible [octreeDepth][]*octreeNode
+	)
+
+	// reduce merges the children of the node with the fewest pixels at the deepest level, until there are at most limit leaves
+	reduce := func(limit int) {
+		for leaves > limit {
+			level := octreeDepth - 1
+			for len(reducible[level]) == 0 {
+				level--
+			}
+			smallest := 0
+			for i, node := range reducible[level] {
+				if node.count < reducible[level][smallest].count {
+					smallest = i
+				}
+			}
+			node := reducible[level][smallest]
+			children := node.distinctChildren()
+			if leaves-len(children)+1 < limit {
+				// Merging all of the children would give too few colors, so only merge the two smallest ones,
+				// by letting the pixels of one of them use the other one
+				sort.Slice(children, func(i, j int) bool { return children[i].count < children[j].count })
+				into, from := children[1], children[0]
+				into.count += from.count
+				into.addSum(from)
+				for i, child := range node.children {
+					if child == from {
+						node.children[i] = into
+					}
+				}
+				leaves--
+				continue
+			}
+			last := len(reducible[level]) - 1
+			reducible[level][smallest] = reducible[level][last]
+			reducible[level] = reducible[level][:last]
+			for _, child := range children {
+				node.addSum(child)
+			}
+			node.children = [16]*octreeNode{}
+			node.leaf = true
+			leaves -= len(children) - 1
+		}
+	}
+
+	// While adding the pixels, keep some more leaves than needed, since the first pixels are not
+	// representative for the whole image
+	limit := n
+	if limit < octreeLeaves {
+		limit = octreeLeaves
+	}
+	for _, p := range pi.pixels {
+		if p.a == 0 {
+			continue
+		}
+		c := [4]int{p.r, p.g, p.b, p.a}
+		node := root
+		node.count++
+		for level := 0; !node.leaf; level++ {
+			if level == octreeDepth {
+				node.leaf = true
+				leaves++
+				break
+			}
+			i := octreeChild(c, level)
+			if node.children[i] == nil {
+				if !node.hasChildren() {
+					reducible[level] = append(reducible[level], node)
+				}
+				node.children[i] = &octreeNode{}
+			}
+			node = node.children[i]
+			node.count++
+		}
+		for k := range c {
+			node.sum[k] += c[k]
+		}
+		reduce(limit)
+	}
+	reduce(n)
+
+	// Replace the colors with the average color of their leaf
+	for _, p := range pi.pixels {
+		if p.a == 0 {
+			continue
+		}
+		c := [4]int{p.r, p.g, p.b, p.a}
+		node := root
+		for level := 0; !node.leaf; level++ {
+			node = node.children[octreeChild(c, level)]
+		}
+		half := node.count / 2
+		p.r = (node.sum[0] + half) / node.count
+		p.g = (node.sum[1] + half) / node.count
+		p.b = (node.sum[2] + half) / node.count
+		p.a = (node.sum[3] + half) / node.count
+	}
+}
+
+// distinctChildren returns the children of the node, where children that share a leaf are only returned once
+func (node *octreeNode) distinctChildren() []*octreeNode {
+	var children []*octreeNode
+next:
+	for _, child := range node.children {
+		if child == nil {
+			continue
+		}
+		for _, found := range children {
+			if child == found {
+				continue next
+			}
+		}
+		children = append(children, child)
+	}
+	return children
+}
+
+// addSum adds the sum of the colors of the other node to this node
+func (node *octreeNode) addSum(other *octreeNode) {
+	for k := range node.sum {
+		node.sum[k] += other.sum[k]
+	}
+}
+
+// hasChildren checks if the node has at least one child
+func (node *octreeNode) hasChildren() bool {
+	for _, child := range node.children {
+		if child != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package png2svg
+
+import "fmt"
+
+// chord is a horizontal or vertical line between two concave corners of a region,
+// that only passes through the inside of the region. For horizontal chords, pos is the y
+// coordinate and from and to are the x coordinates of the ends. For vertical chords it is the other way around.
+type chord struct {
+	pos, from, to int
+}
+
+// CoverOptimal will cover all pixels that are not yet covered by an SVG element, by partitioning each
+// region of one color into the smallest possible number of rectangles that do not overlap. The regions are
+// cut along as many chords between two concave corners as possible, where no two of the chords cross, and
+// the concave corners that are left get one cut each. Since expanded rectangles may overlap rectangles of the
+// same color, expanding rectangles can sometimes give fewer rectangles than this smallest partition.
+func (pi *PixelImage) CoverOptimal() {
+	w, h := pi.w, pi.h
+
+	// same checks if the pixels at the given indices are uncovered and have the same color
+	same := func(i, j int) bool {
+		p, q := pi.pixels[i], pi.pixels[j]
+		return !p.covered && !q.covered && p.r == q.r && p.g == q.g && p.b == q.b && p.a == q.a
+	}
+
+	// The cuts between the pixels. hcut[y*w+x] is above pixel (x, y) and vcut[y*w+x] is to the left of it.
+	hcut := make([]bool, w*h)
+	vcut := make([]bool, w*h)
+
+	// hinside checks if the horizontal line above pixel (x, y) is inside the same region as pixel i, and not cut yet
+	hinside := func(x, y, i int) bool {
+		return x >= 0 && x < w && y > 0 && y < h && same(i, (y-1)*w+x) && same(i, y*w+x) && !hcut[y*w+x]
+	}
+	// vinside checks if the vertical line to the left of pixel (x, y) is inside the same region as pixel i, and not cut yet
+	vinside := func(x, y, i int) bool {
+		return y >= 0 && y < h && x > 0 && x < w && same(i, y*w+x-1) && same(i, y*w+x) && !vcut[y*w+x]
+	}
+
+	// Find the concave corners, where three of the four pixels around a corner are in the same region.
+	// For each one, note the directions of the horizontal and vertical cuts that can start there,
+	// which are away from the pixel that is not in the region.
+	vw := w + 1
+	hdir := make([]int8, vw*(h+1))
+	vdir := make([]int8, vw*(h+1))
+	var concave []int
+	for y := 1; y < h; y++ {
+		for x := 1; x < w; x++ {
+			nw, ne, sw, se := (y-1)*w+x-1, (y-1)*w+x, y*w+x-1, y*w+x
+			var dx, dy int8
+			switch {
+			case same(nw, sw) && same(sw, se) && !same(nw, ne):
+				dx, dy = -1, 1
+			case same(ne, se) && same(se, sw) && !same(ne, nw):
+				dx, dy = 1, 1
+			case same(nw, ne) && same(ne, se) && !same(sw, se):
+				dx, dy = 1, -1
+			case same(ne, nw) && same(nw, sw) && !same(se, sw):
+				dx, dy = -1, -1
+			default:
+				continue
+			}
+			v := y*vw + x
+			hdir[v], vdir[v] = dx, dy
+			concave = append(concave, v)
+		}
+	}
+
+	// hend follows the horizontal line from the given corner in the given direction, for as long as it
+	// is inside a region, and returns where it ends. If stopAtCuts is true, it also stops at vertical cuts.
+	hend := func(x, y, dx int, stopAtCuts bool) int {
+		// The region is the one below the first step
+		i := y*w + x
+		if dx < 0 {
+			i--
+		}
+		for {
+			next := x
+			if dx < 0 {
+				next = x - 1
+			}
+			if !hinside(next, y, i) {
+				return x
+			}
+			x += dx
+			if stopAtCuts && ((x < w && y > 0 && vcut[(y-1)*w+x]) || (x < w && y < h && vcut[y*w+x])) {
+				return x
+			}
+		}
+	}
+	// vend does the same as hend, but for vertical lines
+	vend := func(x, y, dy int, stopAtCuts bool) int {
+		// The region is the one to the right of the first step
+		i := y*w + x
+		if dy < 0 {
+			i -= w
+		}
+		for {
+			next := y
+			if dy < 0 {
+				next = y - 1
+			}
+			if !vinside(x, next, i) {
+				return y
+			}
+			y += dy
+			if stopAtCuts && ((y < h && x > 0 && hcut[y*w+x-1]) || (y < h && x < w && hcut[y*w+x])) {
+				return y
+			}
+		}
+	}
+
+	// Find the chords, which are the cuts that end at another concave corner
+	var hchords, vchords []chord
+	for _, v := range concave {
+		x, y := v%vw, v/vw
+		if hdir[v] > 0 {
+			if end := hend(x, y, 1, false); hdir[y*vw+end] < 0 {
+				hchords = append(hchords, chord{y, x, end})
+			}
+		}
+		if vdir[v] > 0 {
+			if end := vend(x, y, 1, false); vdir[end*vw+x] < 0 {
+				vchords = append(vchords, chord{x, y, end})
+			}
+		}
+	}
+
+	// Find the vertical chords that cross or touch each horizontal chord
+	rows := make(map[int][]int)
+	for i, c := range hchords {
+		rows[c.pos] = append(rows[c.pos], i)
+	}
+	crossing := make([][]int, len(hchords))
+	for j, vc := range vchords {
+		for y := vc.from; y <= vc.to; y++ {
+			for _, i := range rows[y] {
+				if hc := hchords[i]; hc.from <= vc.pos && vc.pos <= hc.to {
+					crossing[i] = append(crossing[i], j)
+				}
+			}
+		}
+	}
+
+	// Find a maximum matching between the crossing chords, by searching for augmenting paths
+	hmatch := make([]int, len(hchords))
+	vmatch := make([]int, len(vchords))
+	for i := range hmatch {
+		hmatch[i] = -1
+	}
+	for j := range vmatch {
+		vmatch[j] = -1
+	}
+	visited := make([]int, len(vchords))
+	var augment func(i, round int) bool
+	augment = func(i, round int) bool {
+		for _, j := range crossing[i] {
+			if visited[j] == round {
+				continue
+			}
+			visited[j] = round
+			if vmatch[j] < 0 || augment(vmatch[j], round) {
+				hmatch[i], vmatch[j] = j, i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range hchords {
+		augment(i, i+1)
+	}
+
+	// The largest set of chords that do not cross is the complement of the smallest vertex cover, which is
+	// found from the matching (König's theorem): the horizontal chords that can be reached from an unmatched
+	// horizontal chord by alternating paths, and the vertical chords that can not.
+	hreached := make([]bool, len(hchords))
+	vreached := make([]bool, len(vchords))
+	var reach func(i int)
+	reach = func(i int) {
+		hreached[i] = true
+		for _, j := range crossing[i] {
+			if !vreached[j] {
+				vreached[j] = true
+				if k := vmatch[j]; k >= 0 && !hreached[k] {
+					reach(k)
+				}
+			}
+		}
+	}
+	for i := range hchords {
+		if hmatch[i] < 0 && !hreached[i] {
+			reach(i)
+		}
+	}
+
+	// Cut along the chords that were selected
+	for i, c := range hchords {
+		if hreached[i] {
+			for x := c.from; x < c.to; x++ {
+				hcut[c.pos*w+x] = true
+			}
+		}
+	}
+	for j, c := range vchords {
+		if !vreached[j] {
+			for y := c.from; y < c.to; y++ {
+				vcut[y*w+c.pos] = true
+			}
+		}
+	}
+
+	// Cut once from each concave corner that has no cut yet, until the cut reaches another cut or the outside
+	for _, v := range concave {
+		x, y := v%vw, v/vw
+		hx := x
+		if hdir[v] < 0 {
+			hx = x - 1
+		}
+		vy := y
+		if vdir[v] < 0 {
+			vy = y - 1
+		}
+		if hcut[y*w+hx] || vcut[vy*w+x] {
+			continue
+		}
+		end := hend(x, y, int(hdir[v]), true)
+		from, to := x, end
+		if to < from {
+			from, to = to, from
+		}
+		for cx := from; cx < to; cx++ {
+			hcut[y*w+cx] = true
+		}
+	}
+
+	// The regions are now cut into rectangles
+	count := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if pi.pixels[i].covered {
+				continue
+			}
+			bw := 1
+			for x+bw < w && same(i, i+bw) && !vcut[i+bw] {
+				bw++
+			}
+			bh := 1
+		grow:
+			for y+bh < h {
+				for dx := 0; dx < bw; dx++ {
+					j := (y+bh)*w + x + dx
+					if !same(i, j) || hcut[j] || (dx > 0 && vcut[j]) {
+						break grow
+					}
+				}
+				bh++
+			}
+			box := pi.CreateBox(x, y)
+			box.w, box.h = bw, bh
+			pi.CoverBox(box, false, pi.colorOptimize)
+			count++
+		}
+	}
+	if pi.verbose {
+		fmt.Printf("Covered the pixels with %d rectangles, with %d concave corners and %d + %d chords.\n", count, len(concave), len(hchords), len(vchords))
+	}
+}
@@ -0,0 +1,248 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// regionStats finds the number of concave corners n, the largest number of chords that do not touch
+// each other l and the number of holes h of the region with the given pixels, which must be connected.
+// By the chord formula, the smallest partition of the region into rectangles has n - l - h + 1 rectangles.
+// ok is false if the region touches itself diagonally, or if there are too many chords to try all subsets.
+func regionStats(in func(x, y int) bool, w, h int) (n, l, holes int, ok bool) {
+	var concave [][2]int
+	for y := 0; y <= h; y++ {
+		for x := 0; x <= w; x++ {
+			nw, ne, sw, se := in(x-1, y-1), in(x, y-1), in(x-1, y), in(x, y)
+			count := 0
+			for _, b := range []bool{nw, ne, sw, se} {
+				if b {
+					count++
+				}
+			}
+			switch {
+			case count == 3:
+				concave = append(concave, [2]int{x, y})
+			case count == 2 && nw == se:
+				// The region touches itself at a corner
+				return 0, 0, 0, false
+			}
+		}
+	}
+	n = len(concave)
+
+	// Find the chords, which are lines between two concave corners that only pass through the region
+	type segment struct{ x0, y0, x1, y1 int }
+	var chords []segment
+	for i, a := range concave {
+		for _, b := range concave[i+1:] {
+			inside := true
+			switch {
+			case a[1] == b[1]:
+				x0, x1 := a[0], b[0]
+				if x0 > x1 {
+					x0, x1 = x1, x0
+				}
+				for x := x0; x < x1 && inside; x++ {
+					inside = in(x, a[1]-1) && in(x, a[1])
+				}
+			case a[0] == b[0]:
+				y0, y1 := a[1], b[1]
+				if y0 > y1 {
+					y0, y1 = y1, y0
+				}
+				for y := y0; y < y1 && inside; y++ {
+					inside = in(a[0]-1, y) && in(a[0], y)
+				}
+			default:
+				inside = false
+			}
+			if inside {
+				s := segment{a[0], a[1], b[0], b[1]}
+				if s.x0 > s.x1 {
+					s.x0, s.x1 = s.x1, s.x0
+				}
+				if s.y0 > s.y1 {
+					s.y0, s.y1 = s.y1, s.y0
+				}
+				chords = append(chords, s)
+			}
+		}
+	}
+	if len(chords) > 16 {
+		return 0, 0, 0, false
+	}
+	touches := func(a, b segment) bool {
+		return a.x0 <= b.x1 && b.x0 <= a.x1 && a.y0 <= b.y1 && b.y0 <= a.y1
+	}
+	for set := 0; set < 1<<uint(len(chords)); set++ {
+		count, fits := 0, true
+		for i := range chords {
+			if set&(1<<uint(i)) == 0 {
+				continue
+			}
+			count++
+			for j := i + 1; j < len(chords) && fits; j++ {
+				if set&(1<<uint(j)) != 0 && touches(chords[i], chords[j]) {
+					fits = false
+				}
+			}
+		}
+		if fits && count > l {
+			l = count
+		}
+	}
+
+	// Count the holes, which are the groups of other pixels that can not be reached from outside of the image
+	reached := make([]bool, (w+2)*(h+2))
+	fill := func(x, y int) {
+		stack := [][2]int{{x, y}}
+		reached[(y+1)*(w+2)+x+1] = true
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				nx, ny := p[0]+d[0], p[1]+d[1]
+				if nx < -1 || ny < -1 || nx > w || ny > h || reached[(ny+1)*(w+2)+nx+1] || in(nx, ny) {
+					continue
+				}
+				reached[(ny+1)*(w+2)+nx+1] = true
+				stack = append(stack, [2]int{nx, ny})
+			}
+		}
+	}
+	fill(-1, -1)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !in(x, y) && !reached[(y+1)*(w+2)+x+1] {
+				holes++
+				fill(x, y)
+			}
+		}
+	}
+	return n, l, holes, true
+}
+
+// expectedOptimal returns the smallest number of rectangles that the regions of one color of the
+// given image can be partitioned into, by the chord formula, or false if it can not be found
+func expectedOptimal(img *image.NRGBA) (int, bool) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	region := make([]int, w*h)
+	for i := range region {
+		region[i] = -1
+	}
+	total, regions := 0, 0
+	for start := range region {
+		if region[start] >= 0 {
+			continue
+		}
+		c := img.NRGBAAt(start%w, start/w)
+		region[start] = regions
+		stack := []int{start}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				nx, ny := x+d[0], y+d[1]
+				if nx >= 0 && ny >= 0 && nx < w && ny < h && region[ny*w+nx] < 0 && img.NRGBAAt(nx, ny) == c {
+					region[ny*w+nx] = regions
+					stack = append(stack, ny*w+nx)
+				}
+			}
+		}
+		r := regions
+		in := func(x, y int) bool {
+			return x >= 0 && y >= 0 && x < w && y < h && region[y*w+x] == r
+		}
+		n, l, holes, ok := regionStats(in, w, h)
+		if !ok {
+			return 0, false
+		}
+		total += n - l - holes + 1
+		regions++
+	}
+	return total, true
+}
+
+// shapeImage returns an image where "#" is a black pixel and any other character is a white pixel
+func shapeImage(rows ...string) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			v := uint8(0xff)
+			if c == '#' {
+				v = 0
+			}
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	return img
+}
+
+func TestCoverOptimalChordFormula(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		n, l, h  int // of the black region
+		expected int // for the whole image
+	}{
+		{"rectangle", []string{"###", "###"}, 0, 0, 0, 1},
+		{"L", []string{"#..", "#..", "###"}, 1, 0, 0, 3},
+		{"plus", []string{".#.", "###", ".#."}, 4, 2, 0, 7},
+		{"ring", []string{"###", "#.#", "###"}, 4, 0, 1, 5},
+		{"H", []string{"#.#", "###", "#.#"}, 4, 2, 0, 5},
+		{"U", []string{"#.#", "#.#", "###"}, 2, 0, 0, 4},
+		{"two holes", []string{"#####", "#.#.#", "#####"}, 8, 2, 2, 7},
+	}
+	for _, test := range tests {
+		img := shapeImage(test.rows...)
+		b := img.Bounds()
+		black := func(x, y int) bool {
+			return x >= 0 && y >= 0 && x < b.Dx() && y < b.Dy() && img.NRGBAAt(x, y).R == 0
+		}
+		if n, l, h, ok := regionStats(black, b.Dx(), b.Dy()); !ok || n != test.n || l != test.l || h != test.h {
+			t.Errorf("%s: expected n=%d, l=%d, h=%d for the black region, got n=%d, l=%d, h=%d",
+				test.name, test.n, test.l, test.h, n, l, h)
+		}
+		if expected, ok := expectedOptimal(img); !ok || expected != test.expected {
+			t.Errorf("%s: expected %d rectangles by the chord formula, got %d", test.name, test.expected, expected)
+		}
+		pi := NewPixelImage(img, false)
+		pi.CoverOptimal()
+		if len(pi.rects) != test.expected {
+			t.Errorf("%s: expected %d rectangles, got %d:\n%s", test.name, test.expected, len(pi.rects), strings.Join(test.rows, "\n"))
+		}
+	}
+}
+
+func TestCoverOptimalRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	checked := 0
+	for checked < 300 {
+		img := image.NewNRGBA(image.Rect(0, 0, 7, 6))
+		for y := 0; y < 6; y++ {
+			for x := 0; x < 7; x++ {
+				v := uint8(r.Intn(2)) * 0xff
+				img.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+			}
+		}
+		expected, ok := expectedOptimal(img)
+		if !ok {
+			continue
+		}
+		checked++
+		pi := NewPixelImage(img, false)
+		pi.CoverOptimal()
+		if !pi.Done(0, 0) {
+			t.Fatal("not all pixels are covered")
+		}
+		if len(pi.rects) != expected {
+			t.Fatalf("expected %d rectangles by the chord formula, got %d, for %v", expected, len(pi.rects), img.Pix)
+		}
+	}
+}
@@ -0,0 +1,582 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Options contains the settings for converting an image to an SVG image
+type Options struct {
+	// LimitColors limits the colors to a maximum of 4096 (#abcdef -> #ace)
+	LimitColors bool
+	// Quantize is deprecated, and is the same as LimitColors
+	Quantize bool
+	// ColorOptimize is deprecated, and is the same as LimitColors
+	ColorOptimize bool
+	// SinglePixelRectangles uses only 1x1 rectangles, instead of expanding them
+	SinglePixelRectangles bool
+	// Runs covers the image with horizontal runs of the same color, instead of expanding rectangles
+	Runs bool
+	// Optimal partitions each region of one color into the smallest possible number of rectangles that do not
+	// overlap. This is not always fewer rectangles than expanding them, since expanded rectangles may overlap.
+	Optimal bool
+	// Quadtree covers the image by recursively splitting it into squares, until each square has one color
+	Quadtree bool
+	// LargestFirst covers the image by placing the largest rectangle of one color first, again and again
+	LargestFirst bool
+	// ColorPink colors the expanded rectangles pink, and turns off SinglePixelRectangles
+	ColorPink bool
+	// ExpandOrder is the direction that rectangles prefer to grow in
+	ExpandOrder ExpandOrder
+	// Checker draws a checkerboard behind transparent regions
+	Checker bool
+	// XMLEncoding is the encoding declared in the XML prolog.
+	// An empty string leaves out the XML prolog. For other encodings than UTF-8, which must be
+	// supersets of ASCII, like ISO-8859-1, text that is not ASCII is written as character references.
+	XMLEncoding string
+	// Layered places the rectangles of each color in a separate group with an id
+	Layered bool
+	// InkscapeLabels turns the layers into Inkscape layers, labeled with the color
+	InkscapeLabels bool
+	// IDs gives each color group and each shape a stable id, like "c0" and "c0-r17"
+	IDs bool
+	// IDPrefix is placed in front of the ids, like "logo-" for "logo-c0-r17"
+	IDPrefix string
+	// Pretty places each element on a separate line, with indentation
+	Pretty bool
+	// Paths draws all rectangles of one color with a single path
+	Paths bool
+	// CompoundPaths merges touching rectangles of the same color into outlines with holes,
+	// and implies Paths
+	CompoundPaths bool
+	// Contours draws each connected region of one color with a path of its own,
+	// and implies Paths
+	Contours bool
+	// Simplify is the tolerance, in pixels, for removing corners from the outlines
+	// of compound paths and contours. It implies CompoundPaths, unless Contours is set.
+	Simplify float64
+	// Smooth draws the outlines of compound paths and contours with curves,
+	// and implies CompoundPaths, unless Contours is set
+	Smooth bool
+	// CornerThreshold is how much, in degrees, a smoothed outline has to turn for the corner to be kept sharp
+	CornerThreshold float64
+	// Outline only draws the outlines of the regions of each color, as stroked paths, and implies CompoundPaths
+	Outline bool
+	// StrokeWidth is the width of the outlines, in pixels
+	StrokeWidth float64
+	// StrokeColor is the color of the outlines, like #000. An empty string gives each outline the color of its region.
+	StrokeColor string
+	// Dots draws a circle in the middle of each pixel, or each Block×Block block, instead of rectangles
+	Dots bool
+	// DotRadius is the radius of the dots, as a fraction of the cell size. 0.5 makes the dots touch.
+	DotRadius float64
+	// DotLuminance scales the dots, so that dark cells get larger dots, like a halftone print
+	DotLuminance bool
+	// Hexagons is the radius, in pixels, of the hexagons that the image is drawn with, instead of rectangles.
+	// 0 draws rectangles.
+	Hexagons float64
+	// Rx is the horizontal radius of rounded corners of the rectangles, in pixels
+	Rx float64
+	// Ry is the vertical radius of rounded corners of the rectangles, in pixels. 0 is the same as Rx.
+	Ry float64
+	// Gap shrinks each rectangle by half the gap, in pixels, on every side
+	Gap float64
+	// Grout is the color of the gaps between the rectangles, like #fff. An empty string leaves the gaps transparent.
+	Grout string
+	// Classes replaces the fill attributes with classes, defined in a <style> block
+	Classes bool
+	// Reuse draws rectangles that have the same size as many others with <use> elements
+	Reuse bool
+	// ShapeRendering is the shape-rendering attribute of the <svg> tag.
+	// The default is "crispEdges", which avoids antialiased seams between the rectangles.
+	ShapeRendering string
+	// ImageRendering is the image-rendering attribute of the <svg> tag, if it is not empty
+	ImageRendering string
+	// PreserveAspectRatio is the preserveAspectRatio attribute of the <svg> tag, if it is not empty
+	PreserveAspectRatio string
+	// Role is the role attribute of the <svg> tag, like "img", if it is not empty
+	Role string
+	// AriaLabel is the aria-label attribute of the <svg> tag, for screen readers, if it is not empty
+	AriaLabel string
+	// Lang is the lang attribute of the <svg> tag, like "en", if it is not empty
+	Lang string
+	// RootAttributes are additional attributes for the <svg> tag
+	RootAttributes map[string]string
+	// Width is the rendered width of the SVG image, in pixels. The viewBox is still the size of the image.
+	// If only one of Width and Height is set, the other one keeps the aspect ratio.
+	Width float64
+	// Height is the rendered height of the SVG image, in pixels
+	Height float64
+	// Scale sets the rendered size of the SVG image to the size of the image times this factor
+	Scale float64
+	// DPI gives the width and height of the SVG image in Unit, where one pixel is 1/DPI inches
+	DPI float64
+	// Unit is the physical unit of the width and height, when DPI is set: "mm", "cm" or "in"
+	Unit string
+	// Title is the text of a <title> element, if it is not empty
+	Title string
+	// Description is the text of a <desc> element, if it is not empty
+	Description string
+	// Metadata adds a <metadata> element with the png2svg version, Source, Flags and the number of rectangles
+	Metadata bool
+	// Source is the source filename that is recorded in the metadata
+	Source string
+	// Flags are the command line flags that are recorded in the metadata
+	Flags string
+	// Profile is the SVG profile: "tiny" for SVG Tiny 1.2, "1.1" for SVG 1.1 or "2" for SVG 2.
+	// An empty string gives an SVG Tiny 1.2 header, without any restrictions on which features are used.
+	Profile string
+	// DominantColor draws the most frequent color as one rectangle across the whole image, as a background
+	DominantColor bool
+	// HexAlpha gives the alpha value of semi-transparent rectangles at the end of the fill color,
+	// like #rrggbbaa, instead of with a fill-opacity attribute
+	HexAlpha bool
+	// NamedColors writes colors that have a shorter CSS color name by that name, like "red" instead of "#f00".
+	// This is the default. If it is false, all colors are written as hex colors.
+	NamedColors bool
+	// Transparent is a key color on the form "#rrggbb" or "#rgb", like #ff00ff, where the pixels that
+	// have it are made fully transparent, before anything else is done with the colors
+	Transparent string
+	// Background is a color on the form "#rrggbb" or "#rgb" that all pixels that are not
+	// fully opaque are blended with, so that the SVG image has no transparency
+	Background string
+	// SkipTransparent keeps the pixels that are fully transparent in the image (or that have the
+	// Transparent key color) transparent, so that no shapes are drawn for them, even if they would
+	// otherwise be blended with the Background color or be averaged into a block
+	SkipTransparent bool
+	// OffsetX moves all x coordinates, after they are scaled by UnitScale
+	OffsetX float64
+	// OffsetY moves all y coordinates, after they are scaled by UnitScale
+	OffsetY float64
+	// UnitScale is the size of one pixel in the coordinates of the SVG image. 0 is the same as 1.
+	UnitScale float64
+	// Precision is the number of decimals that moved or scaled coordinates are rounded to
+	Precision int
+	// Brightness is added to r, g and b, from -1 to 1, before the image is covered with rectangles
+	Brightness float64
+	// Contrast stretches r, g and b away from the middle, from -1 (flat gray) to 1, before the image is covered with rectangles
+	Contrast float64
+	// Gamma makes the dark parts of the image lighter if it is above 1, or darker if it is below 1,
+	// before the image is covered with rectangles. 0 is the same as 1.
+	Gamma float64
+	// Block gives each Block×Block block of pixels the average color of the block, before the image
+	// is covered with rectangles. 0 and 1 leaves the pixels as they are.
+	Block int
+	// BlockMajority uses the most frequent color of each block, instead of the average color
+	BlockMajority bool
+	// ColorMap replaces the colors of the pixels, like "#f00": "#00f", before anything else is done with the colors
+	ColorMap map[string]string
+	// Grayscale converts the colors to gray, before the image is covered with rectangles
+	Grayscale bool
+	// GrayscaleWeights is the way that colors are converted to gray: "rec709" (the default), "rec601" or "average".
+	// It is also used for Duotone.
+	GrayscaleWeights string
+	// DuotoneDark and DuotoneLight are the colors of a ramp, like #000 and #fff, that the gray level of
+	// each pixel is mapped onto, before the image is covered with rectangles. Both or none must be set.
+	DuotoneDark, DuotoneLight string
+	// Sepia is the same as setting DuotoneDark and DuotoneLight to SepiaDark and SepiaLight
+	Sepia bool
+	// Threshold gives the pixels with a gray level below this level, from 1 to 255, the dark color and the
+	// other pixels the light color, before the image is covered with rectangles. 0 leaves the colors as they are.
+	Threshold int
+	// ThresholdDark and ThresholdLight are the two colors that Threshold uses. The default is black and white.
+	ThresholdDark, ThresholdLight string
+	// Posterize reduces each of r, g and b to this many levels, before the image is covered with rectangles.
+	// 0 leaves the colors as they are.
+	Posterize int
+	// Colors reduces the image to at most this many colors, with color quantization,
+	// before the image is covered with rectangles. 0 leaves the colors as they are.
+	Colors int
+	// Quantizer decides how the colors are reduced, when Colors is set. The default is median cut.
+	Quantizer Quantizer
+	// KMeansIterations is the largest number of iterations for the k-means quantizer
+	KMeansIterations int
+	// Palette gives each pixel the closest of these colors, as r, g and b, before the image is covered
+	// with rectangles. See ReadPalette for reading a palette file.
+	Palette [][3]int
+	// Dither spreads the difference between the original colors and the colors given by Colors or Palette
+	// to the pixels around them, with Floyd-Steinberg dithering, so that gradients look smooth from a distance
+	Dither bool
+	// Minify leaves out the optional parts of the SVG document
+	Minify bool
+	// Verbose outputs progress information to stdout
+	Verbose bool
+	// AutoRotate rotates and flips JPEG images according to their EXIF orientation, when Convert reads the image
+	AutoRotate bool
+	// FileMode is the permissions of the files that Convert writes. 0 keeps the permissions of existing files,
+	// and gives new files the default permissions, as limited by the umask.
+	FileMode os.FileMode
+}
+
+// NewOptions returns the default options
+func NewOptions() *Options {
+	return &Options{
+		ExpandOrder:      RightFirst,
+		XMLEncoding:      "UTF-8",
+		ShapeRendering:   "crispEdges",
+		Unit:             "mm",
+		Precision:        3,
+		CornerThreshold:  defaultCornerThreshold,
+		StrokeWidth:      1,
+		DotRadius:        0.5,
+		KMeansIterations: 10,
+		NamedColors:      true,
+		AutoRotate:       true,
+	}
+}
+
+// Validate normalizes the deprecated aliases and settings that override other settings,
+// and returns an error if any of the settings conflict with each other.
+func (o *Options) Validate() error {
+	// -q and -z are the same as -l
+	o.LimitColors = o.LimitColors || o.Quantize || o.ColorOptimize
+	o.Quantize = false
+	o.ColorOptimize = false
+
+	// The pink rectangles are the expanded ones, so there has to be expansion
+	if o.ColorPink {
+		o.SinglePixelRectangles = false
+	}
+
+	if o.ExpandOrder < RightFirst || o.ExpandOrder > Balanced {
+		return errors.New("invalid expand order")
+	}
+	// The SVG document is written as ASCII, which is not the same as UTF-16 or UTF-32
+	if encoding := strings.ToUpper(o.XMLEncoding); strings.HasPrefix(encoding, "UTF-16") || strings.HasPrefix(encoding, "UTF-32") || strings.HasPrefix(encoding, "UCS-") {
+		return errors.New("the XML encoding can not be " + o.XMLEncoding + ", only UTF-8 or an encoding that is a superset of ASCII, like ISO-8859-1")
+	}
+	if o.Pretty && o.Minify {
+		return errors.New("pretty and minified output can not be used together")
+	}
+	if o.InkscapeLabels && !o.Layered {
+		return errors.New("inkscape labels can only be used together with layered output")
+	}
+	if o.IDs && !validIDPrefix(o.IDPrefix) {
+		return fmt.Errorf("invalid id prefix: %q (must start with a letter or _, followed by letters, digits, -, _ or .)", o.IDPrefix)
+	}
+	if o.Simplify < 0 {
+		return errors.New("the simplification tolerance can not be negative")
+	}
+	if o.CornerThreshold < 0 || o.CornerThreshold > 180 {
+		return errors.New("the corner threshold must be from 0 to 180 degrees")
+	}
+	if o.Width < 0 || o.Height < 0 || o.Scale < 0 {
+		return errors.New("the width, height and scale can not be negative")
+	}
+	if o.Scale > 0 && (o.Width > 0 || o.Height > 0) {
+		return errors.New("a scale can not be used together with a width or height")
+	}
+	if o.DPI < 0 {
+		return errors.New("the DPI can not be negative")
+	}
+	switch o.Unit {
+	case "mm", "cm", "in":
+	default:
+		return fmt.Errorf("unknown unit: %s (expected mm, cm or in)", o.Unit)
+	}
+	if o.UnitScale < 0 {
+		return errors.New("the unit scale can not be negative")
+	}
+	if o.StrokeWidth <= 0 {
+		return errors.New("the stroke width must be larger than 0")
+	}
+	if o.StrokeColor != "" {
+		if _, _, _, err := ParseHexColor(o.StrokeColor); err != nil {
+			return err
+		}
+	}
+	if o.Dots && o.DotRadius <= 0 {
+		return errors.New("the dot radius must be larger than 0")
+	}
+	if o.Gap < 0 {
+		return errors.New("the gap can not be negative")
+	}
+	if o.Grout != "" {
+		if _, _, _, err := ParseHexColor(o.Grout); err != nil {
+			return err
+		}
+	}
+	if o.Rx < 0 || o.Ry < 0 {
+		return errors.New("the corner radius can not be negative")
+	}
+	if o.Hexagons < 0 {
+		return errors.New("the hexagon radius can not be negative")
+	}
+	// Only one kind of shape can be drawn, and the rounded corners and gaps only apply to rectangles
+	paths := o.Paths || o.CompoundPaths || o.Contours || o.Simplify > 0 || o.Smooth || o.Outline
+	if o.Dots && o.Hexagons > 0 {
+		return errors.New("dots and hexagons can not be used together")
+	}
+	if (o.Dots || o.Hexagons > 0) && paths {
+		return errors.New("dots and hexagons can not be used together with paths")
+	}
+	if o.Rx > 0 || o.Ry > 0 || o.Gap > 0 {
+		switch {
+		case paths:
+			return errors.New("rounded corners and gaps can not be used together with paths")
+		case o.Dots || o.Hexagons > 0:
+			return errors.New("rounded corners and gaps can not be used together with dots or hexagons")
+		}
+	}
+	if o.Sepia {
+		if o.DuotoneDark != "" || o.DuotoneLight != "" {
+			return errors.New("sepia and duotone can not be used together")
+		}
+		o.DuotoneDark, o.DuotoneLight = SepiaDark, SepiaLight
+		o.Sepia = false
+	}
+	duotone := o.DuotoneDark != "" || o.DuotoneLight != ""
+	if _, err := ParseGrayscaleWeights(o.GrayscaleWeights); (o.Grayscale || duotone || o.Threshold > 0) && err != nil {
+		return err
+	}
+	if duotone {
+		if o.DuotoneDark == "" || o.DuotoneLight == "" {
+			return errors.New("duotone needs both a dark and a light color")
+		}
+		for _, s := range []string{o.DuotoneDark, o.DuotoneLight} {
+			if _, _, _, err := ParseHexColor(s); err != nil {
+				return err
+			}
+		}
+	}
+	if !(o.Brightness >= -1 && o.Brightness <= 1) {
+		return errors.New("the brightness must be from -1 to 1")
+	}
+	if !(o.Contrast >= -1 && o.Contrast <= 1) {
+		return errors.New("the contrast must be from -1 to 1")
+	}
+	if !(o.Gamma >= 0) || math.IsInf(o.Gamma, 1) {
+		return errors.New("the gamma must be a positive number")
+	}
+	if o.Threshold < 0 || o.Threshold > 255 {
+		return errors.New("the threshold must be from 1 to 255, or 0 for no threshold")
+	}
+	for _, s := range []string{o.ThresholdDark, o.ThresholdLight} {
+		if _, _, _, err := ParseHexColor(s); s != "" && err != nil {
+			return err
+		}
+	}
+	if _, err := parseColorMap(o.ColorMap); err != nil {
+		return err
+	}
+	if o.Posterize != 0 && (o.Posterize < 2 || o.Posterize > 256) {
+		return errors.New("the number of posterize levels must be from 2 to 256")
+	}
+	if o.Colors < 0 {
+		return errors.New("the number of colors can not be negative")
+	}
+	if o.Quantizer < MedianCutQuantizer || o.Quantizer > KMeansQuantizer {
+		return errors.New("invalid quantizer")
+	}
+	if o.Quantizer == KMeansQuantizer && o.KMeansIterations < 1 {
+		return errors.New("the number of k-means iterations must be at least 1")
+	}
+	for _, c := range o.Palette {
+		if c[0] < 0 || c[0] > 255 || c[1] < 0 || c[1] > 255 || c[2] < 0 || c[2] > 255 {
+			return fmt.Errorf("invalid color in the palette: %v", c)
+		}
+	}
+	if o.Dither && o.Colors == 0 && len(o.Palette) == 0 {
+		return errors.New("dithering can only be used together with a number of colors or a palette")
+	}
+	if o.Block < 0 {
+		return errors.New("the block size can not be negative")
+	}
+	if o.Precision < 0 || o.Precision > 10 {
+		return errors.New("the precision must be from 0 to 10 decimals")
+	}
+	if o.Transparent != "" {
+		if _, _, _, err := ParseHexColor(o.Transparent); err != nil {
+			return err
+		}
+	}
+	if o.Background != "" {
+		if _, _, _, err := ParseHexColor(o.Background); err != nil {
+			return err
+		}
+		if o.HexAlpha {
+			return errors.New("a background leaves no semi-transparent colors, so it can not be used together with hex alpha")
+		}
+	}
+	switch o.Profile {
+	case "", "tiny", "1.1", "2":
+	default:
+		return fmt.Errorf("unknown SVG profile: %s (expected tiny, 1.1 or 2)", o.Profile)
+	}
+	if o.Profile == "tiny" && o.Classes {
+		return errors.New("style sheets are not a part of SVG Tiny 1.2")
+	}
+	if o.Profile == "tiny" && o.Reuse {
+		return errors.New("reusing rectangles with <use> elements is not supported with the tiny profile (use the 1.1 or 2 profile)")
+	}
+	if o.Profile == "tiny" && o.Checker {
+		return errors.New("patterns, which are used by the checkerboard, are not a part of SVG Tiny 1.2")
+	}
+	for name := range o.RootAttributes {
+		if !validAttributeName(name) {
+			return fmt.Errorf("invalid attribute name: %q", name)
+		}
+	}
+	return nil
+}
+
+// validAttributeName checks if the given string can be used as an XML attribute name
+func validAttributeName(name string) bool {
+	if name == "" || strings.IndexAny(name[:1], "-.0123456789") == 0 {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.:", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// validIDPrefix checks if the given string can be placed in front of the generated ids.
+// An empty prefix is fine, since the ids start with a letter.
+func validIDPrefix(prefix string) bool {
+	if prefix != "" && strings.IndexAny(prefix[:1], "-.0123456789") == 0 {
+		return false
+	}
+	for _, r := range prefix {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_.", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetOptions applies the given options to this PixelImage.
+// If a background color is given, the pixels are blended with it, so this must be done before Cover.
+func (pi *PixelImage) SetOptions(o *Options) {
+	pi.verbose = o.Verbose
+	pi.SetColorOptimize(o.LimitColors)
+	pi.SetExpandOrder(o.ExpandOrder)
+	pi.SetRuns(o.Runs)
+	pi.SetOptimal(o.Optimal)
+	pi.SetQuadtree(o.Quadtree)
+	pi.SetLargestFirst(o.LargestFirst)
+	pi.SetChecker(o.Checker)
+	pi.SetXMLEncoding(o.XMLEncoding)
+	pi.SetLayered(o.Layered)
+	pi.SetInkscapeLabels(o.InkscapeLabels)
+	pi.SetIDs(o.IDs, o.IDPrefix)
+	pi.SetPretty(o.Pretty)
+	pi.SetMinify(o.Minify)
+	pi.SetPaths(o.Paths)
+	pi.SetCompoundPaths(o.CompoundPaths)
+	pi.SetContours(o.Contours)
+	pi.SetSimplify(o.Simplify)
+	pi.SetSmooth(o.Smooth)
+	pi.SetOutline(o.Outline)
+	pi.SetStroke(o.StrokeWidth, o.StrokeColor)
+	pi.SetHexagons(o.Hexagons)
+	pi.SetCornerRadius(o.Rx, o.Ry)
+	pi.SetGap(o.Gap, o.Grout)
+	if o.Dots {
+		pi.SetDots(o.DotRadius, o.Block, o.DotLuminance)
+	} else {
+		pi.SetDots(0, 1, false)
+	}
+	pi.SetCornerThreshold(o.CornerThreshold)
+	pi.SetClasses(o.Classes)
+	pi.SetReuse(o.Reuse)
+	pi.SetRootAttribute("shape-rendering", o.ShapeRendering)
+	pi.SetRootAttribute("image-rendering", o.ImageRendering)
+	pi.SetRootAttribute("preserveAspectRatio", o.PreserveAspectRatio)
+	pi.SetRootAttribute("role", o.Role)
+	pi.SetRootAttribute("aria-label", o.AriaLabel)
+	pi.SetRootAttribute("lang", o.Lang)
+	for name, value := range o.RootAttributes {
+		pi.SetRootAttribute(name, value)
+	}
+	pi.SetDPI(o.DPI, o.Unit)
+	pi.SetTitle(o.Title)
+	pi.SetDescription(o.Description)
+	pi.SetMetadata(o.Metadata, o.Source, o.Flags)
+	pi.SetProfile(o.Profile)
+	pi.SetDominantColor(o.DominantColor)
+	pi.SetHexAlpha(o.HexAlpha)
+	pi.SetNamedColors(o.NamedColors)
+	pi.SetOffset(o.OffsetX, o.OffsetY)
+	pi.SetPrecision(o.Precision)
+	if o.UnitScale > 0 {
+		pi.SetUnitScale(o.UnitScale)
+	}
+	if r, g, b, err := ParseHexColor(o.Transparent); o.Transparent != "" && err == nil {
+		pi.ClearColorKey(r, g, b)
+	}
+	var transparent []bool
+	if o.SkipTransparent {
+		transparent = pi.transparentPixels()
+	}
+	if mapping, err := parseColorMap(o.ColorMap); err == nil {
+		pi.MapColors(mapping)
+	}
+	if r, g, b, err := ParseHexColor(o.Background); o.Background != "" && err == nil {
+		pi.CompositeOver(r, g, b)
+	}
+	pi.Adjust(o.Brightness, o.Contrast, o.Gamma)
+	if o.BlockMajority {
+		pi.MajorityBlocks(o.Block)
+	} else {
+		pi.AverageBlocks(o.Block)
+	}
+	if o.SkipTransparent {
+		pi.clearPixels(transparent)
+	}
+	if weights, err := ParseGrayscaleWeights(o.GrayscaleWeights); err == nil {
+		if o.Grayscale {
+			pi.Grayscale(weights)
+		}
+		dr, dg, db, darkErr := ParseHexColor(o.DuotoneDark)
+		lr, lg, lb, lightErr := ParseHexColor(o.DuotoneLight)
+		if darkErr == nil && lightErr == nil {
+			pi.Duotone(weights, [3]int{dr, dg, db}, [3]int{lr, lg, lb})
+		}
+		if o.Threshold > 0 {
+			dark, light := [3]int{0, 0, 0}, [3]int{255, 255, 255}
+			if r, g, b, err := ParseHexColor(o.ThresholdDark); err == nil {
+				dark = [3]int{r, g, b}
+			}
+			if r, g, b, err := ParseHexColor(o.ThresholdLight); err == nil {
+				light = [3]int{r, g, b}
+			}
+			pi.Threshold(weights, o.Threshold, dark, light)
+		}
+	}
+	pi.Posterize(o.Posterize)
+	// When dithering, the colors are reduced only to find the palette, and then the original colors are dithered
+	var original [][3]int
+	if o.Dither {
+		original = pi.rgb()
+	}
+	switch o.Quantizer {
+	case OctreeQuantizer:
+		pi.Octree(o.Colors)
+	case KMeansQuantizer:
+		pi.KMeans(o.Colors, o.KMeansIterations)
+	default:
+		pi.MedianCut(o.Colors)
+	}
+	if o.Dither && o.Colors == 0 {
+		pi.Dither(o.Palette)
+	} else {
+		pi.SnapToPalette(o.Palette)
+		if o.Dither {
+			palette := pi.paletteColors()
+			pi.setRGB(original)
+			pi.Dither(palette)
+		}
+	}
+	if o.Scale > 0 {
+		pi.SetScale(o.Scale)
+	} else {
+		pi.SetDisplaySize(o.Width, o.Height)
+	}
+}
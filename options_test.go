@@ -0,0 +1,54 @@
+package png2svg
+
+import "testing"
+
+func TestValidateConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		ok   bool
+	}{
+		{"rounded corners", []Option{WithCornerRadius(1, 1)}, true},
+		{"gap", []Option{WithGap(0.5, "")}, true},
+		{"rounded corners and paths", []Option{WithCornerRadius(1, 0), WithPaths(true)}, false},
+		{"rounded corners and compound paths", []Option{WithCornerRadius(1, 0), WithCompoundPaths(true)}, false},
+		{"rounded corners and contours", []Option{WithCornerRadius(0, 1), WithContours(true)}, false},
+		{"rounded corners and simplify", []Option{WithCornerRadius(1, 0), WithSimplify(0.5)}, false},
+		{"rounded corners and smooth", []Option{WithCornerRadius(1, 0), WithSmooth(true)}, false},
+		{"gap and paths", []Option{WithGap(0.5, ""), WithPaths(true)}, false},
+		{"gap and contours", []Option{WithGap(0.5, "#fff"), WithContours(true)}, false},
+		{"gap and outline", []Option{WithGap(0.5, ""), WithOutline(true)}, false},
+		{"gap and dots", []Option{WithGap(0.5, ""), WithDots(0.5, false)}, false},
+		{"rounded corners and hexagons", []Option{WithCornerRadius(1, 1), WithHexagons(2)}, false},
+		{"dots", []Option{WithDots(0.5, false)}, true},
+		{"hexagons", []Option{WithHexagons(2)}, true},
+		{"dots and paths", []Option{WithDots(0.5, false), WithPaths(true)}, false},
+		{"dots and compound paths", []Option{WithDots(0.5, false), WithCompoundPaths(true)}, false},
+		{"hexagons and contours", []Option{WithHexagons(2), WithContours(true)}, false},
+		{"hexagons and smooth", []Option{WithHexagons(2), WithSmooth(true)}, false},
+		{"dots and hexagons", []Option{WithDots(0.5, false), WithHexagons(2)}, false},
+		{"background", []Option{WithBackground("#fff")}, true},
+		{"hex alpha", []Option{WithHexAlpha(true)}, true},
+		{"background and hex alpha", []Option{WithBackground("#fff"), WithHexAlpha(true)}, false},
+		{"reuse", []Option{WithReuse(true)}, true},
+		{"reuse and SVG 1.1", []Option{WithReuse(true), WithProfile("1.1")}, true},
+		{"reuse and SVG 2", []Option{WithReuse(true), WithProfile("2")}, true},
+		{"reuse and SVG Tiny 1.2", []Option{WithReuse(true), WithProfile("tiny")}, false},
+		{"ISO-8859-1", []Option{WithXMLEncoding("ISO-8859-1")}, true},
+		{"UTF-16", []Option{WithXMLEncoding("UTF-16")}, false},
+		{"utf-32le", []Option{WithXMLEncoding("utf-32le")}, false},
+	}
+	for _, test := range tests {
+		o := NewOptions()
+		for _, opt := range test.opts {
+			opt(o)
+		}
+		if err := o.Validate(); (err == nil) != test.ok {
+			if test.ok {
+				t.Errorf("%s: expected no error, got: %v", test.name, err)
+			} else {
+				t.Errorf("%s: expected an error", test.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// ExifOrientation returns the EXIF orientation (1 to 8) found in the given JPEG data.
+// 1 is returned if the data has no orientation tag, which means that no
+// rotation or flipping is needed.
+func ExifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xda || marker == 0xd9 {
+			// Start of scan or end of image, there is no metadata after this
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if marker == 0xe1 {
+			if orientation, ok := tiffOrientation(data[pos+4 : end]); ok {
+				return orientation
+			}
+		}
+		pos = end
+	}
+	return 1
+}
+
+// tiffOrientation looks for the orientation tag in the first IFD of an APP1 Exif segment
+func tiffOrientation(segment []byte) (int, bool) {
+	if !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+		return 0, false
+	}
+	tiff := segment[6:]
+	if len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 0, false
+			}
+			return orientation, true
+		}
+	}
+	return 0, false
+}
+
+// Orient returns a new image where the given EXIF orientation (1 to 8) has been
+// applied, by rotating and/or flipping the image, so that it is the right way up.
+// The image is returned as it is for orientation 1 and for unknown orientations.
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// The width and height are swapped for the orientations that rotate by 90 degrees
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotate 180 degrees
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertically
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 degrees clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 degrees counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, color.NRGBAModel.Convert(img.At(b.Min.X+sx, b.Min.Y+sy)))
+		}
+	}
+	return dst
+}
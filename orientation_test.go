@@ -0,0 +1,58 @@
+package png2svg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestOrientationFixtures(t *testing.T) {
+	// All fixtures show the same 24x16 image when they are the right way up,
+	// with 8x8 blocks of these colors, but are stored rotated and flipped
+	upright := [2][3][3]int{
+		{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}},
+		{{0, 255, 255}, {255, 0, 255}, {255, 255, 0}},
+	}
+	for orientation := 1; orientation <= 8; orientation++ {
+		filename := filepath.Join("testdata", fmt.Sprintf("orientation%d.jpg", orientation))
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ExifOrientation(data); got != orientation {
+			t.Errorf("%s: expected EXIF orientation %d, got %d", filename, orientation, got)
+		}
+
+		// Without rotating, the width and height are swapped for the orientations from 5 to 8
+		stored, err := ReadImage(filename, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, h := 24, 16
+		if orientation >= 5 {
+			w, h = 16, 24
+		}
+		if size := stored.Bounds().Size(); size.X != w || size.Y != h {
+			t.Errorf("%s: expected the stored image to be %dx%d, got %dx%d", filename, w, h, size.X, size.Y)
+		}
+
+		img, err := ReadImage(filename, true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := img.Bounds().Size(); size.X != 24 || size.Y != 16 {
+			t.Fatalf("%s: expected the rotated image to be 24x16, got %dx%d", filename, size.X, size.Y)
+		}
+		for row := 0; row < 2; row++ {
+			for col := 0; col < 3; col++ {
+				// Sample the middle of each block, where each channel is either off or on, despite JPEG artifacts
+				r, g, b, _ := unpremultiply(img.At(img.Bounds().Min.X+col*8+4, img.Bounds().Min.Y+row*8+4).RGBA())
+				want := upright[row][col]
+				if (r > 127) != (want[0] > 127) || (g > 127) != (want[1] > 127) || (b > 127) != (want[2] > 127) {
+					t.Errorf("%s: expected block (%d, %d) to be %v, got [%d %d %d]", filename, col, row, want, r, g, b)
+				}
+			}
+		}
+	}
+}
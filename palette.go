@@ -0,0 +1,170 @@
+package png2svg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadPalette reads a palette file, which can be a GIMP palette (.gpl), a list of hex colors,
+// one per line (.hex), or an Adobe Color Table (.act). The colors are returned as r, g and b.
+func ReadPalette(filename string) ([][3]int, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePalette(data, filepath.Ext(filename))
+}
+
+// ParsePalette parses the contents of a palette file, where the format is given by
+// the file extension: ".gpl", ".hex" or ".act"
+func ParsePalette(data []byte, ext string) ([][3]int, error) {
+	var (
+		palette [][3]int
+		err     error
+	)
+	switch strings.ToLower(ext) {
+	case ".gpl":
+		palette, err = parseGIMPPalette(data)
+	case ".hex", ".txt":
+		palette, err = parseHexPalette(data)
+	case ".act":
+		palette, err = parseACTPalette(data)
+	default:
+		return nil, fmt.Errorf("unknown palette format: %s (must be .gpl, .hex or .act)", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(palette) == 0 {
+		return nil, errors.New("the palette has no colors")
+	}
+	return palette, nil
+}
+
+// parseGIMPPalette parses a GIMP palette, which starts with "GIMP Palette",
+// followed by a line with the red, green and blue values and a name for each color
+func parseGIMPPalette(data []byte) ([][3]int, error) {
+	var palette [][3]int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for i := 0; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i == 0 {
+			if line != "GIMP Palette" {
+				return nil, errors.New("not a GIMP palette: the first line must be \"GIMP Palette\"")
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid color in the GIMP palette: %q", line)
+		}
+		var c [3]int
+		for k := range c {
+			v, err := strconv.Atoi(fields[k])
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid color in the GIMP palette: %q", line)
+			}
+			c[k] = v
+		}
+		palette = append(palette, c)
+	}
+	return palette, scanner.Err()
+}
+
+// parseHexPalette parses a list of colors on the form "rrggbb" or "#rrggbb", one per line.
+// Empty lines and lines that start with ";" are skipped.
+func parseHexPalette(data []byte) ([][3]int, error) {
+	var palette [][3]int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		r, g, b, err := ParseHexColor(line)
+		if err != nil {
+			return nil, err
+		}
+		palette = append(palette, [3]int{r, g, b})
+	}
+	return palette, scanner.Err()
+}
+
+// parseACTPalette parses an Adobe Color Table, which is 256 colors of 3 bytes each, optionally
+// followed by the number of colors that are used, as a 16-bit big-endian number, and 2 more bytes
+func parseACTPalette(data []byte) ([][3]int, error) {
+	if len(data) != 768 && len(data) != 772 {
+		return nil, fmt.Errorf("not an Adobe Color Table: expected 768 or 772 bytes, got %d", len(data))
+	}
+	count := 256
+	if len(data) == 772 {
+		if n := int(data[768])<<8 | int(data[769]); n > 0 && n < 256 {
+			count = n
+		}
+	}
+	palette := make([][3]int, count)
+	for i := range palette {
+		palette[i] = [3]int{int(data[i*3]), int(data[i*3+1]), int(data[i*3+2])}
+	}
+	return palette, nil
+}
+
+// WebSafePalette returns the 216 "web safe" colors, where each of r, g and b is one of
+// 0x00, 0x33, 0x66, 0x99, 0xcc and 0xff. Every color can be written as #rgb.
+func WebSafePalette() [][3]int {
+	palette := make([][3]int, 0, 216)
+	for r := 0; r <= 255; r += 0x33 {
+		for g := 0; g <= 255; g += 0x33 {
+			for b := 0; b <= 255; b += 0x33 {
+				palette = append(palette, [3]int{r, g, b})
+			}
+		}
+	}
+	return palette
+}
+
+// nearestColor returns the color in the palette that is closest to the given color
+func nearestColor(palette [][3]int, r, g, b int) [3]int {
+	var (
+		best         [3]int
+		bestDistance = -1
+	)
+	for _, c := range palette {
+		dr, dg, db := r-c[0], g-c[1], b-c[2]
+		if distance := dr*dr + dg*dg + db*db; bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = c, distance
+		}
+	}
+	return best
+}
+
+// SnapToPalette gives each pixel the color in the palette that is closest to its color, so that
+// the SVG image only uses the colors of a brand palette or a retro system palette. The alpha
+// values are left as they are. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) SnapToPalette(palette [][3]int) {
+	if len(palette) == 0 {
+		return
+	}
+	nearest := make(map[[3]int][3]int)
+	for _, p := range pi.pixels {
+		if p.a == 0 {
+			continue
+		}
+		c := [3]int{p.r, p.g, p.b}
+		n, ok := nearest[c]
+		if !ok {
+			n = nearestColor(palette, p.r, p.g, p.b)
+			nearest[c] = n
+		}
+		p.r, p.g, p.b = n[0], n[1], n[2]
+	}
+}
@@ -0,0 +1,340 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// pathData returns the path data that draws the given rectangles, with one subpath per rectangle.
+// Only the first subpath starts with an absolute "M", the rest are relative to the previous one.
+func pathData(rects []Rect, c coordinates) []byte {
+	var (
+		buf          bytes.Buffer
+		lastx, lasty int
+	)
+	for i, r := range rects {
+		if i == 0 {
+			buf.WriteByte('M')
+			buf.WriteString(c.x(r.X))
+			buf.WriteByte(' ')
+			buf.WriteString(c.y(r.Y))
+		} else {
+			// A closed subpath ends where it started, so the move is relative to the last start
+			buf.WriteByte('m')
+			buf.WriteString(c.dx(lastx, r.X))
+			if dy := c.dy(lasty, r.Y); dy[0] == '-' {
+				// A minus sign is enough to separate the numbers
+				buf.WriteString(dy)
+			} else {
+				buf.WriteByte(' ')
+				buf.WriteString(dy)
+			}
+		}
+		buf.WriteByte('h')
+		buf.WriteString(c.dx(r.X, r.X+r.W))
+		buf.WriteByte('v')
+		buf.WriteString(c.dy(r.Y, r.Y+r.H))
+		buf.WriteByte('h')
+		buf.WriteString(c.dx(r.X+r.W, r.X))
+		buf.WriteByte('z')
+		lastx, lasty = r.X, r.Y
+	}
+	return buf.Bytes()
+}
+
+// pathDocument creates a new SVG document where all rectangles of one color
+// are drawn by a single <path>, instead of by one <rect> each.
+// If compound paths are enabled, touching rectangles are merged into outlines.
+// If contours are enabled, each connected region of one color gets a <path> of its own.
+// The outlines are simplified, if a simplification tolerance is set, and drawn with curves if smoothing is enabled.
+// If outline mode is enabled, the outlines are stroked instead of filled.
+func (pi *PixelImage) pathDocument() (*tinysvg.Document, *tinysvg.Tag) {
+	document, svgTag := tinysvg.NewTinySVG(pi.w, pi.h)
+	tolerance := pi.simplify
+	if pi.smooth && tolerance == 0 {
+		// The steps along a diagonal outline have to be removed before it can be smoothed
+		tolerance = 1
+	}
+	outline := func(loops [][]image.Point) []byte {
+		loops = simplifyLoops(loops, tolerance)
+		if pi.smooth {
+			return smoothLoopData(loops, pi.cornerThreshold, pi.coords)
+		}
+		return loopData(loops, pi.coords)
+	}
+	colors, groups := fillGroups(pi.rects)
+	for _, fill := range colors {
+		var data [][]byte
+		switch {
+		case pi.contours:
+			for _, loops := range contourLoops(groups[fill]) {
+				data = append(data, outline(loops))
+			}
+		case pi.compound || pi.outline || tolerance > 0:
+			data = [][]byte{outline(compoundLoops(groups[fill]))}
+		default:
+			data = [][]byte{pathData(groups[fill], pi.coords)}
+		}
+		rect := groups[fill][0]
+		for _, d := range data {
+			path := svgTag.AddNewTag([]byte("path"))
+			path.AddAttrib("d", d)
+			if pi.outline {
+				pi.addStroke(path, rect)
+				continue
+			}
+			path.Fill(rect.Fill)
+			if rect.Alpha < 255 && !pi.hexAlpha {
+				path.AddAttrib("fill-opacity", []byte(opacityString(rect.Alpha)))
+			}
+		}
+	}
+	return document, svgTag
+}
+
+// addStroke makes the given path draw the outline of the region that has the color of the given
+// rectangle, with the stroke color, or the color of the region if no stroke color is set
+func (pi *PixelImage) addStroke(path *tinysvg.Tag, rect Rect) {
+	path.Fill("none")
+	if pi.strokeColor != "" {
+		path.AddAttrib("stroke", []byte(pi.strokeColor))
+	} else {
+		path.AddAttrib("stroke", shortenColor([]byte(rect.Fill), false))
+		if rect.Alpha < 255 && !pi.hexAlpha {
+			path.AddAttrib("stroke-opacity", []byte(opacityString(rect.Alpha)))
+		}
+	}
+	path.AddAttrib("stroke-width", []byte(formatNumber(pi.strokeWidth*pi.coords.scale)))
+}
+
+// The directions of the edges that outline a region, as bit flags
+const (
+	edgeRight uint8 = 1 << iota
+	edgeDown
+	edgeLeft
+	edgeUp
+)
+
+// compoundLoops returns the outlines of the region that is covered by the given rectangles,
+// where touching rectangles are merged. Each outline and each hole becomes one loop of corners.
+// The outlines run clockwise and the holes run counter-clockwise, so that the default
+// nonzero fill rule leaves the holes empty.
+func compoundLoops(rects []Rect) [][]image.Point {
+	if len(rects) == 0 {
+		return nil
+	}
+	covered, minx, miny, w, h := regionMask(rects)
+	return outlineLoops(covered, w, h, minx, miny)
+}
+
+// contourLoops returns the outlines of each connected region that is covered by the given
+// rectangles, where pixels that share an edge belong to the same region. The regions are found
+// in the order of their top left pixel, and each one is outlined like by compoundLoops.
+func contourLoops(rects []Rect) [][][]image.Point {
+	if len(rects) == 0 {
+		return nil
+	}
+	covered, minx, miny, w, h := regionMask(rects)
+
+	var (
+		regions [][][]image.Point
+		visited = make([]bool, w*h)
+		stack   []int
+	)
+	for start := range covered {
+		if !covered[start] || visited[start] {
+			continue
+		}
+		// Flood fill the region, and find its pixels and bounding box
+		var pixels []int
+		rminx, rminy, rmaxx, rmaxy := w, h, 0, 0
+		visited[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			pixels = append(pixels, i)
+			x, y := i%w, i/w
+			if x < rminx {
+				rminx = x
+			}
+			if y < rminy {
+				rminy = y
+			}
+			if x+1 > rmaxx {
+				rmaxx = x + 1
+			}
+			if y+1 > rmaxy {
+				rmaxy = y + 1
+			}
+			for _, n := range [4]int{i - w, i + 1, i + w, i - 1} {
+				switch {
+				case n == i+1 && x+1 >= w, n == i-1 && x == 0, n < 0, n >= w*h:
+					continue
+				}
+				if covered[n] && !visited[n] {
+					visited[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+		// Outline the pixels of this region only
+		rw, rh := rmaxx-rminx, rmaxy-rminy
+		mask := make([]bool, rw*rh)
+		for _, i := range pixels {
+			mask[(i/w-rminy)*rw+i%w-rminx] = true
+		}
+		regions = append(regions, outlineLoops(mask, rw, rh, minx+rminx, miny+rminy))
+	}
+	return regions
+}
+
+// regionMask returns a mask of the pixels that are covered by the given rectangles,
+// together with the position and size of their bounding box
+func regionMask(rects []Rect) (covered []bool, minx, miny, w, h int) {
+	// Find the bounding box of the region
+	minx, miny = rects[0].X, rects[0].Y
+	maxx, maxy := rects[0].X+rects[0].W, rects[0].Y+rects[0].H
+	for _, r := range rects[1:] {
+		if r.X < minx {
+			minx = r.X
+		}
+		if r.Y < miny {
+			miny = r.Y
+		}
+		if r.X+r.W > maxx {
+			maxx = r.X + r.W
+		}
+		if r.Y+r.H > maxy {
+			maxy = r.Y + r.H
+		}
+	}
+	w, h = maxx-minx, maxy-miny
+
+	// Mark the covered pixels
+	covered = make([]bool, w*h)
+	for _, r := range rects {
+		for y := r.Y - miny; y < r.Y-miny+r.H; y++ {
+			for x := r.X - minx; x < r.X-minx+r.W; x++ {
+				covered[y*w+x] = true
+			}
+		}
+	}
+	return covered, minx, miny, w, h
+}
+
+// outlineLoops returns the outlines of the covered pixels of a w×h mask, where the top left pixel
+// of the mask is at (minx, miny) in the image. Each loop is a list of the corners along one outline.
+func outlineLoops(covered []bool, w, h, minx, miny int) [][]image.Point {
+	inside := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && covered[y*w+x]
+	}
+
+	// Find the edges between covered and uncovered pixels, stored as the directions
+	// that can be followed from each corner, so that the region is on the right hand side
+	vw := w + 1
+	edges := make([]uint8, vw*(h+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !covered[y*w+x] {
+				continue
+			}
+			if !inside(x, y-1) {
+				edges[y*vw+x] |= edgeRight
+			}
+			if !inside(x+1, y) {
+				edges[y*vw+x+1] |= edgeDown
+			}
+			if !inside(x, y+1) {
+				edges[(y+1)*vw+x+1] |= edgeLeft
+			}
+			if !inside(x-1, y) {
+				edges[(y+1)*vw+x] |= edgeUp
+			}
+		}
+	}
+
+	// Follow the edges from the top left corner of each outline, until all edges are used
+	var loops [][]image.Point
+	for v, e := range edges {
+		for e != 0 {
+			// Walk along the outline and note each corner, until it is closed.
+			// Since every corner has as many edges going in as going out, the walk can only end where it started.
+			x, y := v%vw, v/vw
+			loop := []image.Point{{X: x + minx, Y: y + miny}}
+			var last uint8
+			cx, cy := x, y
+			for edges[cy*vw+cx] != 0 {
+				out := edges[cy*vw+cx]
+				// Prefer to continue in the same direction, to get longer runs
+				dir := out & -out
+				if out&last != 0 {
+					dir = last
+				} else if last != 0 {
+					loop = append(loop, image.Point{X: cx + minx, Y: cy + miny})
+				}
+				last = dir
+				edges[cy*vw+cx] &^= dir
+				switch dir {
+				case edgeRight:
+					cx++
+				case edgeDown:
+					cy++
+				case edgeLeft:
+					cx--
+				case edgeUp:
+					cy--
+				}
+			}
+			loops = append(loops, loop)
+			e = edges[v]
+		}
+	}
+	return loops
+}
+
+// loopData returns the path data that draws the given loops of corners, with one subpath per loop.
+// Only the first subpath starts with an absolute "M", the rest are relative to the previous one.
+// The last side of each loop is drawn by closing the subpath.
+func loopData(loops [][]image.Point, c coordinates) []byte {
+	var buf bytes.Buffer
+	// writeNumber writes the given number, with a space in front if it is needed as a separator
+	writeNumber := func(s string, separate bool) {
+		if separate && s[0] != '-' {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(s)
+	}
+	for i, loop := range loops {
+		if i == 0 {
+			buf.WriteByte('M')
+			writeNumber(c.x(loop[0].X), false)
+			writeNumber(c.y(loop[0].Y), true)
+		} else {
+			// A closed subpath ends where it started, so the move is relative to the last start
+			last := loops[i-1][0]
+			buf.WriteByte('m')
+			writeNumber(c.dx(last.X, loop[0].X), false)
+			writeNumber(c.dy(last.Y, loop[0].Y), true)
+		}
+		for j := 1; j < len(loop); j++ {
+			from, to := loop[j-1], loop[j]
+			switch {
+			case from.Y == to.Y:
+				buf.WriteByte('h')
+				writeNumber(c.dx(from.X, to.X), false)
+			case from.X == to.X:
+				buf.WriteByte('v')
+				writeNumber(c.dy(from.Y, to.Y), false)
+			default:
+				buf.WriteByte('l')
+				writeNumber(c.dx(from.X, to.X), false)
+				writeNumber(c.dy(from.Y, to.Y), true)
+			}
+		}
+		buf.WriteByte('z')
+	}
+	return buf.Bytes()
+}
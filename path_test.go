@@ -0,0 +1,155 @@
+package png2svg
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// pathToken matches a command or a number in path data
+var pathToken = regexp.MustCompile(`[MmHhVvLlZz]|-?[0-9]*\.?[0-9]+`)
+
+// absoluteSubpaths follows the given path data, which may use relative commands,
+// and returns the absolute position of each corner, one list per subpath
+func absoluteSubpaths(d string) ([][][2]float64, error) {
+	var (
+		subpaths [][][2]float64
+		x, y     float64
+		command  string
+		numbers  []float64
+	)
+	tokens := pathToken.FindAllString(d, -1)
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			if v, err := strconv.ParseFloat(tokens[i], 64); err == nil {
+				numbers = append(numbers, v)
+				continue
+			}
+		}
+		// A new command, so carry out the previous one
+		switch command {
+		case "":
+		case "M", "m":
+			if len(numbers) != 2 {
+				return nil, fmt.Errorf("expected 2 numbers for %s, got %v", command, numbers)
+			}
+			if command == "M" {
+				x, y = numbers[0], numbers[1]
+			} else {
+				x, y = x+numbers[0], y+numbers[1]
+			}
+			subpaths = append(subpaths, [][2]float64{{x, y}})
+		case "h", "v", "l":
+			switch {
+			case command == "h" && len(numbers) == 1:
+				x += numbers[0]
+			case command == "v" && len(numbers) == 1:
+				y += numbers[0]
+			case command == "l" && len(numbers) == 2:
+				x, y = x+numbers[0], y+numbers[1]
+			default:
+				return nil, fmt.Errorf("wrong number of numbers for %s: %v", command, numbers)
+			}
+			last := len(subpaths) - 1
+			subpaths[last] = append(subpaths[last], [2]float64{x, y})
+		case "z":
+			// Closing a subpath moves back to where it started
+			start := subpaths[len(subpaths)-1][0]
+			x, y = start[0], start[1]
+		default:
+			return nil, fmt.Errorf("unexpected command: %s", command)
+		}
+		if i < len(tokens) {
+			command, numbers = tokens[i], nil
+		}
+	}
+	return subpaths, nil
+}
+
+// samePoints checks if the given absolute corners are the expected ones
+func samePoints(got [][][2]float64, want [][][2]float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if len(got[i]) != len(want[i]) {
+			return false
+		}
+		for j := range got[i] {
+			if math.Abs(got[i][j][0]-want[i][j][0]) > 1e-9 || math.Abs(got[i][j][1]-want[i][j][1]) > 1e-9 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pathTestCoordinates are the ways of writing coordinates that the path data is checked with
+var pathTestCoordinates = []coordinates{
+	{scale: 1, precision: 3},
+	{offsetX: 3.25, offsetY: -7, scale: 0.5, precision: 3},
+	{offsetX: 0.1, offsetY: 0.2, scale: 1.0 / 3, precision: 2},
+}
+
+// pathTestRects are rectangles that cover the two regions of a small image, with a hole in one of them
+var pathTestRects = []Rect{
+	{X: 0, Y: 0, W: 5, H: 1},
+	{X: 0, Y: 1, W: 1, H: 3},
+	{X: 4, Y: 1, W: 1, H: 3},
+	{X: 0, Y: 4, W: 5, H: 1},
+	{X: 7, Y: 2, W: 2, H: 6},
+	{X: 6, Y: 9, W: 1, H: 1},
+}
+
+func TestPathDataRelativeMatchesAbsolute(t *testing.T) {
+	for _, c := range pathTestCoordinates {
+		var want [][][2]float64
+		for _, r := range pathTestRects {
+			x0, y0, x1, y1 := c.xAt(r.X), c.yAt(r.Y), c.xAt(r.X+r.W), c.yAt(r.Y+r.H)
+			want = append(want, [][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}})
+		}
+		d := string(pathData(pathTestRects, c))
+		got, err := absoluteSubpaths(d)
+		if err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+		if !samePoints(got, want) {
+			t.Errorf("%+v: the relative path data %q draws %v, expected %v", c, d, got, want)
+		}
+	}
+}
+
+func TestLoopDataRelativeMatchesAbsolute(t *testing.T) {
+	loops := compoundLoops(pathTestRects)
+	for _, c := range pathTestCoordinates {
+		var want [][][2]float64
+		for _, loop := range loops {
+			var corners [][2]float64
+			for _, p := range loop {
+				corners = append(corners, [2]float64{c.xAt(p.X), c.yAt(p.Y)})
+			}
+			want = append(want, corners)
+		}
+		d := string(loopData(loops, c))
+		got, err := absoluteSubpaths(d)
+		if err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+		if !samePoints(got, want) {
+			t.Errorf("%+v: the relative path data %q draws %v, expected %v", c, d, got, want)
+		}
+	}
+	// The diagonal sides of simplified outlines are drawn with "l"
+	diagonal := [][]image.Point{{{X: 0, Y: 0}, {X: 3, Y: 0}, {X: 0, Y: 3}}, {{X: 5, Y: 5}, {X: 6, Y: 7}, {X: 4, Y: 8}}}
+	got, err := absoluteSubpaths(string(loopData(diagonal, pathTestCoordinates[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][][2]float64{{{0, 0}, {3, 0}, {0, 3}}, {{5, 5}, {6, 7}, {4, 8}}}
+	if !samePoints(got, want) {
+		t.Errorf("the relative path data draws %v, expected %v", got, want)
+	}
+}
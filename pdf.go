@@ -0,0 +1,97 @@
+package png2svg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// fillGroups returns the rectangles grouped by fill color, with the colors in the order they first appear
+func fillGroups(rects []Rect) ([]string, map[string][]Rect) {
+	var colors []string
+	groups := make(map[string][]Rect)
+	for _, r := range rects {
+		key := r.Fill
+		if r.Alpha < 255 {
+			// Semi-transparent rectangles are grouped by both color and alpha
+			key += "/" + strconv.Itoa(r.Alpha)
+		}
+		if _, ok := groups[key]; !ok {
+			colors = append(colors, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+	return colors, groups
+}
+
+// PDF returns the placed rectangles as a single page PDF document, with the same
+// coordinates and fill colors as the SVG image. One pixel is one point (1/72 inch).
+// Semi-transparent rectangles are drawn with an ExtGState that sets the fill opacity.
+func (pi *PixelImage) PDF() ([]byte, error) {
+	if !pi.Done(0, 0) {
+		return nil, errors.New("the SVG representation does not cover all pixels")
+	}
+
+	// The page content, where the coordinate system is flipped so that y grows downwards, like in SVG
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "1 0 0 -1 0 %d cm\n", pi.h)
+	var (
+		states    bytes.Buffer
+		hasStates = make(map[int]bool)
+	)
+	colors, groups := fillGroups(pi.rects)
+	for _, fill := range colors {
+		rects := groups[fill]
+		alpha := rects[0].Alpha
+		if alpha < 255 {
+			// One graphics state per alpha value, which is only used for this group
+			if !hasStates[alpha] {
+				fmt.Fprintf(&states, " /A%d << /Type /ExtGState /ca %s >>", alpha, pdfNumber(alpha))
+				hasStates[alpha] = true
+			}
+			fmt.Fprintf(&content, "q /A%d gs\n", alpha)
+		}
+		r, g, b := rects[0].RGB()
+		fmt.Fprintf(&content, "%s %s %s rg\n", pdfNumber(r), pdfNumber(g), pdfNumber(b))
+		for _, rect := range rects {
+			fmt.Fprintf(&content, "%d %d %d %d re\n", rect.X, rect.Y, rect.W, rect.H)
+		}
+		content.WriteString("f\n")
+		if alpha < 255 {
+			content.WriteString("Q\n")
+		}
+	}
+	resources := "<< >>"
+	if states.Len() > 0 {
+		resources = "<< /ExtGState <<" + states.String() + " >> >>"
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents 4 0 R /Resources %s >>", pi.w, pi.h, resources),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes(), nil
+}
+
+// pdfNumber returns the given 8-bit color value as a number from 0 to 1, with at most 3 decimals
+func pdfNumber(v int) string {
+	return strconv.FormatFloat(math.Round(float64(v)/255.0*1000)/1000, 'f', -1, 64)
+}
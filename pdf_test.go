@@ -0,0 +1,59 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// halfTransparentImage returns a 2x1 image with an opaque red pixel and a half transparent blue pixel
+func halfTransparentImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{0, 0, 0xff, 0x80})
+	return img
+}
+
+func TestPDFFillOpacity(t *testing.T) {
+	pi := NewPixelImage(halfTransparentImage(), false)
+	pi.Cover(false, false)
+	data, err := pi.PDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"/Resources << /ExtGState << /A128 << /Type /ExtGState /ca 0.502 >> >> >>",
+		"1 0 0 rg\n0 0 1 1 re\nf\n",
+		"q /A128 gs\n0 0 1 rg\n1 0 1 1 re\nf\nQ\n",
+	} {
+		if !bytes.Contains(data, []byte(expected)) {
+			t.Errorf("expected %q in the PDF document:\n%s", expected, data)
+		}
+	}
+
+	// Opaque images need no graphics states
+	pi = NewPixelImage(uniformImage(2, 2, color.NRGBA{0xff, 0, 0, 0xff}), false)
+	pi.Cover(false, false)
+	if data, err = pi.PDF(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("ExtGState")) || !bytes.Contains(data, []byte("/Resources << >>")) {
+		t.Errorf("expected no graphics states in the PDF document:\n%s", data)
+	}
+}
+
+func TestEPSBlendsTransparency(t *testing.T) {
+	pi := NewPixelImage(halfTransparentImage(), false)
+	pi.Cover(false, false)
+	data, err := pi.EPS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Half transparent blue on white paper is light blue
+	for _, expected := range []string{"1 0 0 setrgbcolor\n0 0 1 1 R\n", "0.498 0.498 1 setrgbcolor\n1 0 1 1 R\n"} {
+		if !bytes.Contains(data, []byte(expected)) {
+			t.Errorf("expected %q in the EPS document:\n%s", expected, data)
+		}
+	}
+}
@@ -2,15 +2,19 @@ package png2svg
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"html"
 	"image"
-	"image/color"
 	"image/png"
+	"io"
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-
-	"github.com/xyproto/tinysvg"
 )
 
 // Pixel represents a pixel at position (x,y)
@@ -31,17 +35,64 @@ type Pixels []*Pixel
 
 // PixelImage contains the data needed to convert a PNG to an SVG:
 // pixels (with an overview of which pixels are covered) and
-// an SVG document, starting with the document and root tag +
+// the rectangles that has been placed, which are rendered as an SVG document by Bytes +
 // colorOptimize, for if only 4096 colors should be used
 // (short hex color strings, like #fff).
 type PixelImage struct {
-	pixels        Pixels
-	document      *tinysvg.Document
-	svgTag        *tinysvg.Tag
-	verbose       bool
-	w             int
-	h             int
-	colorOptimize bool
+	pixels          Pixels
+	verbose         bool
+	w               int
+	h               int
+	colorOptimize   bool
+	layered         bool
+	inkscape        bool
+	ids             bool
+	idPrefix        string
+	expandOrder     ExpandOrder
+	checker         bool
+	xmlEncoding     string
+	pretty          bool
+	minify          bool
+	paths           bool
+	compound        bool
+	contours        bool
+	simplify        float64
+	smooth          bool
+	cornerThreshold float64
+	outline         bool
+	strokeWidth     float64
+	strokeColor     string
+	dotRadius       float64
+	dotCell         int
+	dotLuminance    bool
+	hexRadius       float64
+	rx, ry          float64
+	gap             float64
+	grout           string
+	classes         bool
+	reuse           bool
+	rootAttribs     map[string]string
+	displayW        float64
+	displayH        float64
+	dpi             float64
+	unit            string
+	title           string
+	description     string
+	profile         string
+	dominant        bool
+	hexAlpha        bool
+	namedColors     bool
+	coords          coordinates
+	runs            bool
+	optimal         bool
+	quadtree        bool
+	largestFirst    bool
+	metadata        bool
+	source          string
+	flags           string
+	rng             *rand.Rand
+	rects           []Rect
+	fillColors      map[string]struct{}
 }
 
 // SetColorOptimize can be used to set the colorOptimize flag,
@@ -50,8 +101,305 @@ func (pi *PixelImage) SetColorOptimize(enabled bool) {
 	pi.colorOptimize = enabled
 }
 
+// SetExpandOrder can be used to select which direction boxes prefer to grow in,
+// when they are expanded. The default is RightFirst.
+func (pi *PixelImage) SetExpandOrder(order ExpandOrder) {
+	pi.expandOrder = order
+}
+
+// SetChecker can be used to draw a checkerboard pattern behind the image,
+// to make transparent regions obvious when viewing the SVG image.
+// The checkerboard is only added if the image has transparent pixels.
+func (pi *PixelImage) SetChecker(enabled bool) {
+	pi.checker = enabled
+}
+
+// SetXMLEncoding can be used to set the encoding that is declared in the
+// XML prolog, like "UTF-8" or "ISO-8859-1". The default is "UTF-8".
+// If the encoding is an empty string, the XML prolog is left out.
+// For other encodings, text that is not ASCII, like in the title, is written as character references.
+func (pi *PixelImage) SetXMLEncoding(encoding string) {
+	pi.xmlEncoding = encoding
+}
+
+// SetLayered can be used to place the rectangles of each color in a separate
+// group with an id on the form "layer-rrggbb", even if there is only one
+// rectangle of that color. This makes it easy to select by color in editors.
+func (pi *PixelImage) SetLayered(enabled bool) {
+	pi.layered = enabled
+}
+
+// SetInkscapeLabels can be used to turn the layer groups into Inkscape layers,
+// with inkscape:groupmode and inkscape:label attributes, so that each color
+// can be edited as a layer of its own. Only used when layered output is enabled.
+func (pi *PixelImage) SetInkscapeLabels(enabled bool) {
+	pi.inkscape = enabled
+}
+
+// SetIDs can be used to give each color group an id on the form "c0", and each
+// shape an id on the form "c0-r17", where the numbers are the index of the color,
+// in the order that the colors first appear, and the index of the shape within
+// that color. The ids start with the given prefix, so that several SVG images can
+// be placed in the same HTML document. The same image and options always give
+// the same ids, so that regions can be animated with CSS or JavaScript.
+func (pi *PixelImage) SetIDs(enabled bool, prefix string) {
+	pi.ids = enabled
+	pi.idPrefix = prefix
+}
+
+// SetSeed can be used to seed the random number generator that is used by
+// CreateRandomBox. The default seed is 1, so that the output is always the same.
+func (pi *PixelImage) SetSeed(seed int64) {
+	pi.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetPretty can be used to place each element on a separate line, with indentation,
+// which makes the SVG document easier to read and diff.
+func (pi *PixelImage) SetPretty(enabled bool) {
+	pi.pretty = enabled
+}
+
+// SetPaths can be used to draw all rectangles of one color with a single <path>,
+// which is typically half the size of the <rect> elements it replaces.
+func (pi *PixelImage) SetPaths(enabled bool) {
+	pi.paths = enabled
+}
+
+// SetCompoundPaths can be used to merge touching rectangles of the same color into
+// outlines with holes, so that each color region is one subpath. This implies paths.
+func (pi *PixelImage) SetCompoundPaths(enabled bool) {
+	pi.compound = enabled
+}
+
+// SetContours can be used to trace the outline of each connected region of one color,
+// and draw every region with a <path> of its own. This implies paths.
+func (pi *PixelImage) SetContours(enabled bool) {
+	pi.contours = enabled
+}
+
+// SetSimplify can be used to simplify the outlines of compound paths and contours, by removing
+// corners that are at most the given tolerance (in pixels) away from the simplified outline.
+// This gives fewer nodes, at the cost of small deviations from the pixels. It implies compound paths,
+// unless contours are enabled. The default is 0, for no simplification.
+func (pi *PixelImage) SetSimplify(tolerance float64) {
+	pi.simplify = tolerance
+}
+
+// SetSmooth can be used to draw the outlines of compound paths and contours with curves,
+// so that low resolution logos can be scaled up without looking blocky.
+// This implies compound paths, and a simplification tolerance of 1 pixel if none is set.
+func (pi *PixelImage) SetSmooth(enabled bool) {
+	pi.smooth = enabled
+}
+
+// SetCornerThreshold can be used to set how much, in degrees, a smoothed outline has to turn
+// for the corner to be kept sharp. The default is 80 degrees. 180 smooths all corners.
+func (pi *PixelImage) SetCornerThreshold(degrees float64) {
+	pi.cornerThreshold = degrees
+}
+
+// SetOutline can be used to only draw the outlines of the regions of each color, as stroked paths,
+// for coloring books or pen plotters. This implies compound paths.
+func (pi *PixelImage) SetOutline(enabled bool) {
+	pi.outline = enabled
+}
+
+// SetStroke can be used to set the width, in pixels, and the color of the outlines.
+// The default is a width of 1 pixel. An empty color gives each outline the color of its region.
+func (pi *PixelImage) SetStroke(width float64, color string) {
+	pi.strokeWidth = width
+	pi.strokeColor = color
+}
+
+// SetDots can be used to draw a <circle> in the middle of each cell×cell cell, instead of rectangles,
+// for halftone-like output. The radius is a fraction of the cell size, where 0.5 makes the dots
+// touch. If byLuminance is true, the radius is scaled so that dark cells get larger dots.
+// A radius of 0 turns the dots off.
+func (pi *PixelImage) SetDots(radius float64, cell int, byLuminance bool) {
+	if cell < 1 {
+		cell = 1
+	}
+	pi.dotRadius = radius
+	pi.dotCell = cell
+	pi.dotLuminance = byLuminance
+}
+
+// SetHexagons can be used to draw the image with hexagons that have the given radius, in pixels,
+// instead of rectangles, for stylized output like a game map. Each hexagon gets the color
+// of the pixel at its center. A radius of 0 turns the hexagons off.
+func (pi *PixelImage) SetHexagons(radius float64) {
+	pi.hexRadius = radius
+}
+
+// SetCornerRadius can be used to give the rectangles rounded corners, with the given horizontal
+// and vertical radius, in pixels. If only rx is set, it is also used as the vertical radius.
+// This is for "soft pixel" renditions of pixel art. Paths are not rounded.
+func (pi *PixelImage) SetCornerRadius(rx, ry float64) {
+	pi.rx = rx
+	pi.ry = ry
+}
+
+// SetGap can be used to shrink each rectangle by half the given gap, in pixels, on every side,
+// for mosaic-like output. If the grout color is not empty, the gaps are filled with that color,
+// by drawing it behind the whole image.
+func (pi *PixelImage) SetGap(gap float64, grout string) {
+	pi.gap = gap
+	pi.grout = grout
+}
+
+// SetClasses can be used to replace the fill attributes with classes that are
+// defined in a <style> block, one per color, so that the image can be recolored
+// by editing a single style sheet.
+func (pi *PixelImage) SetClasses(enabled bool) {
+	pi.classes = enabled
+}
+
+// SetReuse can be used to draw rectangles that have the same size as many other
+// rectangles with <use> elements, that refer to a single rectangle in <defs>.
+// This is a big win for sprite sheets full of equally sized blocks.
+func (pi *PixelImage) SetReuse(enabled bool) {
+	pi.reuse = enabled
+}
+
+// SetRootAttribute can be used to set an attribute of the <svg> tag, like
+// shape-rendering, image-rendering or preserveAspectRatio. An empty value
+// leaves out the attribute. The default is shape-rendering="crispEdges",
+// which avoids antialiased seams between the rectangles.
+func (pi *PixelImage) SetRootAttribute(name, value string) {
+	if value == "" {
+		delete(pi.rootAttribs, name)
+		return
+	}
+	pi.rootAttribs[name] = value
+}
+
+// SetDisplaySize can be used to set the rendered width and height of the SVG image, in pixels,
+// while the viewBox is still the size of the image. A width or height of 0 is calculated from
+// the other one, keeping the aspect ratio. If both are 0, the size of the image is used.
+func (pi *PixelImage) SetDisplaySize(width, height float64) {
+	switch {
+	case width > 0 && height <= 0:
+		height = width * float64(pi.h) / float64(pi.w)
+	case height > 0 && width <= 0:
+		width = height * float64(pi.w) / float64(pi.h)
+	}
+	pi.displayW, pi.displayH = width, height
+}
+
+// SetScale can be used to set the rendered size of the SVG image to the size of the image times the given factor
+func (pi *PixelImage) SetScale(scale float64) {
+	pi.SetDisplaySize(float64(pi.w)*scale, float64(pi.h)*scale)
+}
+
+// SetDPI can be used to give the width and height of the SVG image in a physical unit: "mm", "cm" or "in",
+// where one pixel is 1/dpi inches. This is useful for laser cutting and printing. A dpi of 0 uses pixels.
+func (pi *PixelImage) SetDPI(dpi float64, unit string) {
+	pi.dpi, pi.unit = dpi, unit
+}
+
+// SetTitle can be used to add a <title> element to the SVG image,
+// for screen readers and asset managers. An empty title leaves it out.
+func (pi *PixelImage) SetTitle(title string) {
+	pi.title = title
+}
+
+// SetDescription can be used to add a <desc> element to the SVG image.
+// An empty description leaves it out.
+func (pi *PixelImage) SetDescription(description string) {
+	pi.description = description
+}
+
+// SetMetadata can be used to add a <metadata> element that records the png2svg version,
+// the given source filename and flags and the number of rectangles, so that the
+// SVG image can be traced back to where it came from, and regenerated later.
+func (pi *PixelImage) SetMetadata(enabled bool, source, flags string) {
+	pi.metadata = enabled
+	pi.source = source
+	pi.flags = flags
+}
+
+// SetProfile can be used to select the SVG profile: "tiny" for SVG Tiny 1.2, "1.1" for SVG 1.1
+// or "2" for SVG 2. This changes the header and how <use> elements refer to rectangles.
+// The default is an SVG Tiny 1.2 header, without any restrictions on which features are used.
+// Use Options.Validate to check which features can be used with a profile.
+func (pi *PixelImage) SetProfile(profile string) {
+	pi.profile = profile
+}
+
+// SetDominantColor can be used to draw the most frequent color as one rectangle across
+// the whole image, before the other rectangles are placed by Cover. See CoverDominantColor.
+func (pi *PixelImage) SetDominantColor(enabled bool) {
+	pi.dominant = enabled
+}
+
+// SetHexAlpha can be used to give the alpha value of semi-transparent rectangles at
+// the end of the fill color, like #rrggbbaa, instead of with a fill-opacity attribute.
+// Note that 8-digit hex colors are a part of CSS Color Level 4, not of SVG 1.1 or SVG Tiny 1.2.
+func (pi *PixelImage) SetHexAlpha(enabled bool) {
+	pi.hexAlpha = enabled
+}
+
+// SetNamedColors can be used to write fill colors that have a shorter CSS color name by that name,
+// like "red" instead of "#f00" and "navy" instead of "#000080", which is the default.
+// If it is disabled, all colors are written as hex colors.
+func (pi *PixelImage) SetNamedColors(enabled bool) {
+	pi.namedColors = enabled
+}
+
+// SetOffset can be used to move all coordinates by the given offset, after they are scaled
+// with SetUnitScale, so that the SVG image can be placed directly into another document.
+// The viewBox is moved too, so that the SVG image looks the same.
+func (pi *PixelImage) SetOffset(x, y float64) {
+	pi.coords.offsetX, pi.coords.offsetY = x, y
+}
+
+// SetUnitScale can be used to set the size of one pixel in the coordinates of the SVG image.
+// The default is 1. The viewBox is scaled too, so that the SVG image looks the same.
+func (pi *PixelImage) SetUnitScale(scale float64) {
+	pi.coords.scale = scale
+}
+
+// SetPrecision can be used to set the number of decimals that coordinates are rounded to,
+// when they are moved or scaled. The default is 3.
+func (pi *PixelImage) SetPrecision(decimals int) {
+	pi.coords.precision = decimals
+}
+
+// SetRuns can be used to make Cover place horizontal runs of the same color, one row at the time,
+// instead of expanding rectangles. See CoverRuns.
+func (pi *PixelImage) SetRuns(enabled bool) {
+	pi.runs = enabled
+}
+
+// SetOptimal can be used to make Cover partition each region of one color into the smallest possible
+// number of rectangles that do not overlap, instead of expanding rectangles. See CoverOptimal.
+func (pi *PixelImage) SetOptimal(enabled bool) {
+	pi.optimal = enabled
+}
+
+// SetQuadtree can be used to make Cover split the image into squares of one color,
+// instead of expanding rectangles. See CoverQuadtree.
+func (pi *PixelImage) SetQuadtree(enabled bool) {
+	pi.quadtree = enabled
+}
+
+// SetLargestFirst can be used to make Cover place the largest rectangles first,
+// instead of expanding rectangles from the top left. See CoverLargestFirst.
+func (pi *PixelImage) SetLargestFirst(enabled bool) {
+	pi.largestFirst = enabled
+}
+
+// SetMinify can be used to leave out the optional parts of the SVG document:
+// the XML prolog (unless another encoding than UTF-8 is set), the version and
+// baseProfile attributes and the "px" unit of the image width and height.
+func (pi *PixelImage) SetMinify(enabled bool) {
+	pi.minify = enabled
+}
+
 // ReadPNG tries to read the given PNG image filename and returns and image.Image
 // and an error. If verbose is true, some basic information is printed to stdout.
+// Any trailing bytes after the IEND chunk are ignored. Color profiles and
+// color space information (iCCP, sRGB, gAMA and cHRM chunks) are not applied.
 func ReadPNG(filename string, verbose bool) (image.Image, error) {
 	if verbose {
 		fmt.Printf("Reading %s", filename)
@@ -62,7 +410,29 @@ func ReadPNG(filename string, verbose bool) (image.Image, error) {
 		return nil, err
 	}
 	defer f.Close()
-	img, err := png.Decode(f)
+	return Decode(f, verbose)
+}
+
+// ReadPNGFrom tries to read a PNG image from the given io.Reader, like stdin,
+// and returns an image.Image and an error. See ReadPNG.
+func ReadPNGFrom(r io.Reader, verbose bool) (image.Image, error) {
+	if verbose {
+		fmt.Print("Reading PNG image")
+		defer fmt.Println()
+	}
+	return Decode(r, verbose)
+}
+
+// Decode reads and decodes a PNG image from the given io.Reader, like an embedded file,
+// a network stream or an in-memory buffer, and returns an image.Image and an error.
+// If verbose is true, the image size and any warnings are printed to stdout.
+// Any trailing bytes after the IEND chunk are ignored.
+func Decode(r io.Reader, verbose bool) (image.Image, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodePNG(data, verbose)
 	if err != nil {
 		return nil, err
 	}
@@ -72,21 +442,98 @@ func ReadPNG(filename string, verbose bool) (image.Image, error) {
 	return img, nil
 }
 
+// decodePNG decodes the given PNG data, while ignoring any trailing bytes after the IEND chunk.
+// If verbose is true, warnings are printed to stdout.
+func decodePNG(data []byte, verbose bool) (image.Image, error) {
+	data, trailing := trimAfterIEND(data)
+	if verbose && trailing > 0 {
+		fmt.Printf(" (warning: ignoring %d trailing bytes after IEND)", trailing)
+	}
+	if names := colorSpaceChunks(data); verbose && len(names) > 0 {
+		fmt.Printf(" (warning: found %s, but color profiles are not applied)", strings.Join(names, ", "))
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
 // Erase characters on the terminal
 func Erase(n int) {
 	fmt.Print(strings.Repeat("\b", n))
 }
 
+// unpremultiply converts the alpha-premultiplied 16-bit color values that are
+// returned by color.Color.RGBA to straight 8-bit color values, so that
+// semi-transparent pixels are not darkened when their color is used as a fill.
+func unpremultiply(r, g, b, a uint32) (int, int, int, int) {
+	switch a {
+	case 0:
+		return 0, 0, 0, 0
+	case 0xffff:
+		return int(r >> 8), int(g >> 8), int(b >> 8), 255
+	}
+	r = (r * 0xffff) / a
+	g = (g * 0xffff) / a
+	b = (b * 0xffff) / a
+	return int(r >> 8), int(g >> 8), int(b >> 8), int(a >> 8)
+}
+
+// hexColorBytes returns the given color as a hex color string on the form "#rrggbb"
+func hexColorBytes(r, g, b int) []byte {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", r, g, b))
+}
+
 // NewPixelImage initializes a new PixelImage struct,
 // given an image.Image.
 func NewPixelImage(img image.Image, verbose bool) *PixelImage {
+	pi := &PixelImage{
+		verbose:         verbose,
+		xmlEncoding:     "UTF-8",
+		rng:             rand.New(rand.NewSource(1)),
+		rootAttribs:     map[string]string{"shape-rendering": "crispEdges"},
+		coords:          coordinates{scale: 1, precision: 3},
+		cornerThreshold: defaultCornerThreshold,
+		strokeWidth:     1,
+		namedColors:     true,
+	}
+	pi.Reset(img)
+	return pi
+}
+
+// NewPixelImageFromRGBA initializes a new PixelImage struct, given raw pixel data,
+// like from a framebuffer. The pixels are w*h 8-bit R, G, B and A values, row by row,
+// where the colors are not premultiplied by the alpha value.
+func NewPixelImageFromRGBA(pix []byte, w, h int) (*PixelImage, error) {
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("invalid image size")
+	}
+	if len(pix) < w*h*4 {
+		return nil, fmt.Errorf("expected %d bytes of RGBA pixel data for a %dx%d image, got %d", w*h*4, w, h, len(pix))
+	}
+	img := &image.NRGBA{
+		Pix:    pix,
+		Stride: w * 4,
+		Rect:   image.Rect(0, 0, w, h),
+	}
+	return NewPixelImage(img, false), nil
+}
+
+// Reset prepares the PixelImage for converting a new image.
+// The pixels are reused if the new image has the same size as the previous one,
+// which makes it cheaper to convert many images (like animation frames) in a row.
+// Options that has been set on the PixelImage are kept.
+func (pi *PixelImage) Reset(img image.Image) {
 	width := img.Bounds().Max.X - img.Bounds().Min.X
 	height := img.Bounds().Max.Y - img.Bounds().Min.Y
 
-	pixels := make(Pixels, width*height)
+	if width != pi.w || height != pi.h || len(pi.pixels) != width*height {
+		// Allocate all pixels in one go
+		backing := make([]Pixel, width*height)
+		pi.pixels = make(Pixels, width*height)
+		for i := range pi.pixels {
+			pi.pixels[i] = &backing[i]
+		}
+	}
 
-	var c color.NRGBA
-	if verbose {
+	if pi.verbose {
 		fmt.Print("Interpreting image... 0%")
 	}
 
@@ -97,7 +544,7 @@ func NewPixelImage(img image.Image, verbose bool) *PixelImage {
 
 	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
 
-		if verbose && y != lastLine {
+		if pi.verbose && y != lastLine {
 			lastPercentage = percentage
 			percentage = int((float64(y) / float64(height)) * 100.0)
 			Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
@@ -106,24 +553,25 @@ func NewPixelImage(img image.Image, verbose bool) *PixelImage {
 		}
 
 		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-			c = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
-			alpha := int(c.A)
+			r, g, b, alpha := unpremultiply(img.At(x, y).RGBA())
 			// Mark transparent pixels as already being "covered"
 			covered := alpha == 0
-			pixels[i] = &Pixel{x, y, int(c.R), int(c.G), int(c.B), alpha, covered}
+			*pi.pixels[i] = Pixel{x, y, r, g, b, alpha, covered}
 			i++
 		}
 	}
 
-	// Create a new XML document with a new SVG tag
-	document, svgTag := tinysvg.NewTinySVG(width, height)
+	pi.w = width
+	pi.h = height
 
-	if verbose {
+	// Reset the statistics
+	pi.rects = nil
+	pi.fillColors = nil
+
+	if pi.verbose {
 		Erase(len(fmt.Sprintf("%d%%", lastPercentage)))
 		fmt.Println("100%")
 	}
-
-	return &PixelImage{pixels, document, svgTag, verbose, width, height, false}
 }
 
 // Done checks if all pixels are covered, in terms of being represented by an SVG element
@@ -142,6 +590,16 @@ func (pi *PixelImage) Done(startx, starty int) bool {
 	return true
 }
 
+// HasTransparency returns true if any of the pixels are not fully opaque
+func (pi *PixelImage) HasTransparency() bool {
+	for _, p := range pi.pixels {
+		if p.a < 255 {
+			return true
+		}
+	}
+	return false
+}
+
 // At returns the RGB color at the given coordinate
 func (pi *PixelImage) At(x, y int) (r, g, b int) {
 	i := y*pi.w + x
@@ -175,7 +633,7 @@ func (pi *PixelImage) CoverAllPixels() {
 	coverCount := 0
 	for _, p := range pi.pixels {
 		if !(*p).covered {
-			pi.svgTag.Pixel((*p).x, (*p).y, (*p).r, (*p).g, (*p).b)
+			pi.addRect((*p).x, (*p).y, 1, 1, string(hexColorBytes((*p).r, (*p).g, (*p).b)), (*p).a)
 			(*p).covered = true
 			coverCount++
 		}
@@ -185,6 +643,63 @@ func (pi *PixelImage) CoverAllPixels() {
 	}
 }
 
+// CoverRuns will cover all pixels that are not yet covered by an SVG element,
+// by creating a rectangle for each horizontal run of pixels with the same color.
+// This is O(width×height), which is much faster than expanding rectangles for huge
+// images, and the result is often nearly as small, after compression.
+func (pi *PixelImage) CoverRuns() {
+	for y := 0; y < pi.h; y++ {
+		for x := 0; x < pi.w; x++ {
+			p := pi.pixels[y*pi.w+x]
+			if p.covered {
+				continue
+			}
+			w := 1
+			for x+w < pi.w {
+				q := pi.pixels[y*pi.w+x+w]
+				if q.covered || q.r != p.r || q.g != p.g || q.b != p.b || q.a != p.a {
+					break
+				}
+				w++
+			}
+			pi.CoverBox(&Box{x, y, w, 1, p.r, p.g, p.b, p.a}, false, pi.colorOptimize)
+			x += w - 1
+		}
+	}
+	if pi.verbose {
+		fmt.Printf("Covered the pixels with %d horizontal runs.\n", len(pi.rects))
+	}
+}
+
+// addRect adds a rectangle with the given fill color and alpha value (0 to 255) to the SVG image,
+// and updates the statistics. Semi-transparent rectangles get an alpha value at the end of the
+// fill color, if hex alpha is enabled, or else a fill-opacity attribute when they are rendered.
+func (pi *PixelImage) addRect(x, y, w, h int, colorString string, alpha int) {
+	if alpha < 255 && pi.hexAlpha {
+		colorString = withAlpha(colorString, alpha)
+	}
+	pi.rects = append(pi.rects, Rect{x, y, w, h, colorString, alpha})
+	if pi.fillColors == nil {
+		pi.fillColors = make(map[string]struct{})
+	}
+	pi.fillColors[colorString] = struct{}{}
+}
+
+// Rectangles returns the rectangles that has been placed so far, in the order they were placed
+func (pi *PixelImage) Rectangles() []Rect {
+	return pi.rects
+}
+
+// RectangleCount returns the number of rectangles that has been placed so far
+func (pi *PixelImage) RectangleCount() int {
+	return len(pi.rects)
+}
+
+// ColorCount returns the number of unique fill colors used by the rectangles that has been placed so far
+func (pi *PixelImage) ColorCount() int {
+	return len(pi.fillColors)
+}
+
 // FirstUncovered will find the first pixel that is not covered by an SVG element,
 // starting from (startx,starty), searching row-wise, downwards.
 func (pi *PixelImage) FirstUncovered(startx, starty int) (int, int) {
@@ -203,15 +718,21 @@ func (pi *PixelImage) FirstUncovered(startx, starty int) (int, int) {
 }
 
 func shortenColor(hexColorBytes []byte, colorOptimize bool) []byte {
-	if colorOptimize && len(hexColorBytes) > 5 {
-		// Use the shorthand form: #a?c?d? -> #acd
-		return []byte{'#', hexColorBytes[1], hexColorBytes[3], hexColorBytes[5]}
-	} else if len(hexColorBytes) > 5 && hexColorBytes[1] == hexColorBytes[2] && hexColorBytes[3] == hexColorBytes[4] && hexColorBytes[5] == hexColorBytes[6] {
-		// Use the shorthand form: #aaccdd -> #acd and #0000ff -> #00f
-		return []byte{'#', hexColorBytes[1], hexColorBytes[3], hexColorBytes[5]}
+	if len(hexColorBytes) != 7 && len(hexColorBytes) != 9 {
+		// Return the unmodified color
+		return hexColorBytes
+	}
+	// Use the shorthand form: #a?c?d? -> #acd if colorOptimize is true,
+	// or else #aaccdd -> #acd and #0000ff -> #00f. Colors with alpha, like #aaccddee, are also shortened.
+	short := []byte{'#'}
+	for i := 1; i < len(hexColorBytes); i += 2 {
+		if !colorOptimize && hexColorBytes[i] != hexColorBytes[i+1] {
+			// Return the unmodified color
+			return hexColorBytes
+		}
+		short = append(short, hexColorBytes[i])
 	}
-	// Return the unmodified color
-	return hexColorBytes
+	return short
 }
 
 // colorFromLine will extract the fill color from a svg rect line.
@@ -235,14 +756,20 @@ func colorFromLine(line []byte, colorOptimize bool) ([]byte, []byte, bool) {
 	return nil, nil, false
 }
 
-// groupLinesByFillColor will group lines that has a fill color by color, organized under <g> tags
+// groupLinesByFillColor will group lines that has a fill color by color, organized under <g fill="..."> tags,
+// where the fill attribute is removed from the grouped lines. Colors with only one line are not grouped.
+// If layered is true, all colors get their own group, with an id. If inkscape is also true,
+// the groups are marked as Inkscape layers, labeled with the color.
+// If ids is true, the groups and the grouped lines get ids that start with idPrefix, like "c0" and "c0-r17".
 // This is not the prettiest function, but it works.
 // TODO: Rewrite, to make it prettier
 // TODO: Benchmark
-func groupLinesByFillColor(lines [][]byte, colorOptimize bool) [][]byte {
+func groupLinesByFillColor(lines [][]byte, colorOptimize, layered, inkscape, ids bool, idPrefix string) [][]byte {
 	// Group lines by fill color
 	var (
 		groupedLines                  = make(map[string][][]byte)
+		keys                          []string               // the colors, in the order they first appear
+		indices                       = make(map[string]int) // the index of each color in keys
 		fillColor, shortenedFillColor []byte
 		found                         bool
 	)
@@ -259,8 +786,13 @@ func groupLinesByFillColor(lines [][]byte, colorOptimize bool) [][]byte {
 		if _, ok := groupedLines[cs]; !ok {
 			// Start an empty line
 			groupedLines[cs] = make([][]byte, 0)
+			indices[cs] = len(keys)
+			keys = append(keys, cs)
 		}
 		line = bytes.Replace(line, fillColor, shortenedFillColor, 1)
+		if ids {
+			line = insertID(line, idPrefix+"c"+strconv.Itoa(indices[cs])+"-r"+strconv.Itoa(len(groupedLines[cs])))
+		}
 		line = append(line, '>')
 		groupedLines[cs] = append(groupedLines[cs], line)
 	}
@@ -274,9 +806,36 @@ func groupLinesByFillColor(lines [][]byte, colorOptimize bool) [][]byte {
 		buf  bytes.Buffer
 		from []byte
 	)
-	for key, lines := range groupedLines {
-		if len(lines) > 1 {
-			buf.Write([]byte("<g fill=\""))
+	for _, key := range keys {
+		lines := groupedLines[key]
+		if layered {
+			if ids {
+				buf.Write([]byte("<g id=\""))
+				buf.WriteString(idPrefix + "c" + strconv.Itoa(indices[key]))
+			} else {
+				buf.Write([]byte("<g id=\"layer-"))
+				buf.WriteString(key[1:])
+			}
+			if inkscape {
+				buf.Write([]byte("\" inkscape:groupmode=\"layer\" inkscape:label=\""))
+				buf.WriteString(key)
+			}
+			buf.Write([]byte("\" fill=\""))
+			buf.WriteString(key)
+			buf.Write([]byte("\">"))
+			for _, line := range lines {
+				from = append([]byte(" fill=\""), key...)
+				buf.Write(bytes.Replace(line, append(from, '"'), []byte{}, 1))
+			}
+			buf.Write([]byte("</g>"))
+		} else if len(lines) > 1 {
+			buf.Write([]byte("<g "))
+			if ids {
+				buf.Write([]byte("id=\""))
+				buf.WriteString(idPrefix + "c" + strconv.Itoa(indices[key]))
+				buf.Write([]byte("\" "))
+			}
+			buf.Write([]byte("fill=\""))
 			//fmt.Printf("WRITING KEY %s\n", key)
 			buf.WriteString(key)
 			buf.Write([]byte("\">"))
@@ -300,15 +859,66 @@ func groupLinesByFillColor(lines [][]byte, colorOptimize bool) [][]byte {
 	return lines
 }
 
-// Bytes returns the rendered SVG document as bytes
+// Bytes returns the rendered SVG document as bytes, without writing anything to a file
 func (pi *PixelImage) Bytes() []byte {
 	if pi.verbose {
 		fmt.Print("Rendering SVG...")
 	}
 
+	// Draw the rectangles of each color as a single path, if paths are enabled
+	document, svgTag := pi.rectDocument()
+	if pi.hexRadius > 0 {
+		document, svgTag = pi.hexDocument()
+	} else if pi.dotRadius > 0 {
+		document, svgTag = pi.dotDocument()
+	} else if pi.paths || pi.compound || pi.contours || pi.simplify > 0 || pi.smooth || pi.outline {
+		document, svgTag = pi.pathDocument()
+	}
+	if !pi.coords.identity() {
+		svgTag.AddAttrib("viewBox", []byte(pi.coords.viewBox(pi.w, pi.h)))
+	}
+
+	// Add the attributes of the <svg> tag. They are ordered when the document is rendered.
+	for name, value := range pi.rootAttribs {
+		svgTag.AddAttrib(name, []byte(html.EscapeString(value)))
+	}
+
+	// Set the rendered size, if it is not the size of the image
+	if pi.displayW > 0 && pi.displayH > 0 || pi.dpi > 0 {
+		width, height, unit := float64(pi.w), float64(pi.h), "px"
+		if pi.displayW > 0 && pi.displayH > 0 {
+			width, height = pi.displayW, pi.displayH
+		}
+		if pi.dpi > 0 {
+			// Convert from pixels to inches, and then to the physical unit
+			factor := 1 / pi.dpi
+			switch pi.unit {
+			case "mm":
+				factor *= 25.4
+			case "cm":
+				factor *= 2.54
+			}
+			width, height, unit = width*factor, height*factor, pi.unit
+		}
+		svgTag.AddAttrib("width", []byte(formatNumber(width)+unit))
+		svgTag.AddAttrib("height", []byte(formatNumber(height)+unit))
+	}
+
+	// Only SVG 2 supports "href" without the xlink namespace
+	href := "href"
+	if pi.reuse && pi.profile != "2" {
+		href = "xlink:href"
+		svgTag.AddAttrib("xmlns:xlink", []byte("http://www.w3.org/1999/xlink"))
+	}
+
+	// Declare the inkscape namespace, if Inkscape layers are going to be used
+	if pi.layered && pi.inkscape {
+		svgTag.AddAttrib("xmlns:inkscape", []byte("http://www.inkscape.org/namespaces/inkscape"))
+	}
+
 	// Render the SVG document
-	// TODO: pi.document.WriteTo also exists, and might be faster
-	svgDocument := pi.document.Bytes()
+	// TODO: document.WriteTo also exists, and might be faster
+	svgDocument := document.Bytes()
 
 	if pi.verbose {
 		fmt.Println("ok")
@@ -319,7 +929,14 @@ func (pi *PixelImage) Bytes() []byte {
 
 	// Group lines by fill color, insert <g> tags
 	lines := bytes.Split(svgDocument, []byte(">"))
-	lines = groupLinesByFillColor(lines, pi.colorOptimize)
+	for i, line := range lines {
+		lines[i] = orderAttributes(line)
+	}
+	var defs []byte
+	if pi.reuse {
+		lines, defs = useLines(lines, href)
+	}
+	lines = groupLinesByFillColor(lines, pi.colorOptimize, pi.layered, pi.inkscape, pi.ids, pi.idPrefix)
 
 	for i, line := range lines {
 		if len(line) > 0 && !bytes.HasSuffix(line, []byte(">")) {
@@ -328,6 +945,9 @@ func (pi *PixelImage) Bytes() []byte {
 	}
 	// Use the line contents as the new svgDocument
 	svgDocument = bytes.Join(lines, []byte{})
+	if defs != nil {
+		svgDocument = insertAfterSVGTag(svgDocument, defs)
+	}
 
 	if pi.verbose {
 		fmt.Println("ok")
@@ -356,45 +976,110 @@ func (pi *PixelImage) Bytes() []byte {
 	svgDocument = bytes.Replace(svgDocument, []byte(" height=\"0\""), []byte{}, -1)
 	svgDocument = bytes.Replace(svgDocument, []byte("> <"), []byte("><"), -1)
 
-	// Replacement of colors that are not shortened, colors that has been shortened
-	// and color names to even shorter strings.
-	colorReplacements := map[string][]byte{
-		"#f0ffff": []byte("azure"),
-		"#f5f5dc": []byte("beige"),
-		"#ffe4c4": []byte("bisque"),
-		"#a52a2a": []byte("brown"),
-		"#ff7f50": []byte("coral"),
-		"#ffd700": []byte("gold"),
-		"#808080": []byte("gray"), // "grey" is also possible
-		"#008000": []byte("green"),
-		"#4b0082": []byte("indigo"),
-		"#fffff0": []byte("ivory"),
-		"#f0e68c": []byte("khaki"),
-		"#faf0e6": []byte("linen"),
-		"#800000": []byte("maroon"),
-		"#000080": []byte("navy"),
-		"#808000": []byte("olive"),
-		"#ffa500": []byte("orange"),
-		"#da70d6": []byte("orchid"),
-		"#cd853f": []byte("peru"),
-		"#ffc0cb": []byte("pink"),
-		"#dda0dd": []byte("plum"),
-		"#800080": []byte("purple"),
-		"#f00":    []byte("red"),
-		"#fa8072": []byte("salmon"),
-		"#a0522d": []byte("sienna"),
-		"#c0c0c0": []byte("silver"),
-		"#fffafa": []byte("snow"),
-		"#d2b48c": []byte("tan"),
-		"#008080": []byte("teal"),
-		"#ff6347": []byte("tomato"),
-		"#ee82ee": []byte("violet"),
-		"#f5deb3": []byte("wheat"),
-	}
-
-	// Replace colors with the shorter version
-	for k, v := range colorReplacements {
-		svgDocument = bytes.Replace(svgDocument, []byte(k), v, -1)
+	// Use the CSS color names that are shorter than the hex colors, unless only hex colors are wanted
+	if pi.namedColors {
+		// Replacement of colors that are not shortened, colors that has been shortened
+		// and color names to even shorter strings.
+		colorReplacements := map[string][]byte{
+			"#f0ffff": []byte("azure"),
+			"#f5f5dc": []byte("beige"),
+			"#ffe4c4": []byte("bisque"),
+			"#a52a2a": []byte("brown"),
+			"#ff7f50": []byte("coral"),
+			"#ffd700": []byte("gold"),
+			"#808080": []byte("gray"), // "grey" is also possible
+			"#008000": []byte("green"),
+			"#4b0082": []byte("indigo"),
+			"#fffff0": []byte("ivory"),
+			"#f0e68c": []byte("khaki"),
+			"#faf0e6": []byte("linen"),
+			"#800000": []byte("maroon"),
+			"#000080": []byte("navy"),
+			"#808000": []byte("olive"),
+			"#ffa500": []byte("orange"),
+			"#da70d6": []byte("orchid"),
+			"#cd853f": []byte("peru"),
+			"#ffc0cb": []byte("pink"),
+			"#dda0dd": []byte("plum"),
+			"#800080": []byte("purple"),
+			"#f00":    []byte("red"),
+			"#fa8072": []byte("salmon"),
+			"#a0522d": []byte("sienna"),
+			"#c0c0c0": []byte("silver"),
+			"#fffafa": []byte("snow"),
+			"#d2b48c": []byte("tan"),
+			"#008080": []byte("teal"),
+			"#ff6347": []byte("tomato"),
+			"#ee82ee": []byte("violet"),
+			"#f5deb3": []byte("wheat"),
+		}
+
+		// Replace colors with the shorter version, in sorted order, so that the output is always the same
+		colors := make([]string, 0, len(colorReplacements))
+		for k := range colorReplacements {
+			colors = append(colors, k)
+		}
+		sort.Strings(colors)
+		// The quotes are included, so that only whole colors are replaced, and not the start of longer ones
+		for _, k := range colors {
+			svgDocument = bytes.Replace(svgDocument, []byte(`"`+k+`"`), append(append([]byte(`"`), colorReplacements[k]...), '"'), -1)
+		}
+	}
+
+	// Use one class per fill color, defined in a style sheet
+	if pi.classes {
+		svgDocument = classMarkup(svgDocument)
+	}
+
+	// Fill the gaps between the rectangles
+	if pi.grout != "" {
+		c := pi.coords
+		grout := `<rect x="` + c.x(0) + `" y="` + c.y(0) + `" width="` + c.dx(0, pi.w) + `" height="` + c.dy(0, pi.h) + `" fill="` + pi.grout + `"/>`
+		grout = strings.Replace(strings.Replace(grout, ` x="0"`, "", 1), ` y="0"`, "", 1)
+		svgDocument = insertAfterSVGTag(svgDocument, []byte(grout))
+	}
+
+	// Draw a checkerboard behind the image, if there is any transparency to visualize
+	if pi.checker && pi.HasTransparency() {
+		checker := checkerMarkup(pi.w, pi.h)
+		if !pi.coords.identity() {
+			// The checkerboard is in pixel coordinates
+			checker = append(append([]byte(`<g transform="translate(`+pi.coords.x(0)+" "+pi.coords.y(0)+") scale("+pi.coords.format(pi.coords.scale)+`)">`), checker...), "</g>"...)
+		}
+		svgDocument = insertAfterSVGTag(svgDocument, checker)
+	}
+
+	// Record how the SVG image was generated
+	if pi.metadata {
+		svgDocument = insertAfterSVGTag(svgDocument, metadataMarkup(pi.source, pi.flags, pi.RectangleCount()))
+	}
+
+	// Add the title and description as the first elements
+	if pi.title != "" || pi.description != "" {
+		svgDocument = insertAfterSVGTag(svgDocument, titleMarkup(pi.title, pi.description))
+	}
+
+	// Use the header of the selected SVG profile
+	svgDocument = profileMarkup(svgDocument, pi.profile)
+
+	// Leave out the optional parts
+	if pi.minify {
+		svgDocument = minifyMarkup(svgDocument)
+	}
+
+	// Replace the XML prolog, if a different encoding (or no prolog) is wanted
+	if pi.xmlEncoding != "UTF-8" {
+		svgDocument = bytes.TrimPrefix(svgDocument, []byte(`<?xml version="1.0" encoding="UTF-8"?>`))
+		if pi.xmlEncoding != "" {
+			// Only ASCII is the same in UTF-8 and the declared encoding
+			svgDocument = asciiMarkup(svgDocument)
+			svgDocument = append([]byte(`<?xml version="1.0" encoding="`+pi.xmlEncoding+`"?>`), svgDocument...)
+		}
+	}
+
+	// Place each element on a separate line
+	if pi.pretty {
+		svgDocument = indentMarkup(svgDocument)
 	}
 
 	if pi.verbose {
@@ -404,31 +1089,67 @@ func (pi *PixelImage) Bytes() []byte {
 	return svgDocument
 }
 
-// WriteSVG will save the current SVG document to a file
-func (pi *PixelImage) WriteSVG(filename string) error {
-	var (
-		err error
-		f   *os.File
-	)
+// String returns the rendered SVG document as a string
+func (pi *PixelImage) String() string {
+	return string(pi.Bytes())
+}
 
+// SVGZ compresses the given SVG image with gzip, which is the format of .svgz files
+func SVGZ(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteSVGTo writes the current SVG document to the given io.Writer,
+// like a HTTP response or a buffer
+func (pi *PixelImage) WriteSVGTo(w io.Writer) error {
 	if !pi.Done(0, 0) {
 		return errors.New("the SVG representation does not cover all pixels")
 	}
+	_, err := w.Write(pi.Bytes())
+	return err
+}
+
+// WriteSVG will save the current SVG document to a file, or to stdout if the filename is "-".
+// If the filename ends with ".svgz", the SVG document is compressed with gzip.
+// The SVG document is written to a temporary file in the same directory, which is then
+// renamed, so that a failed write never leaves a truncated file behind. An existing file
+// keeps its permissions, and a new file gets the default permissions, as limited by the umask.
+func (pi *PixelImage) WriteSVG(filename string) (err error) {
+	var f *os.File
 	if filename == "-" {
 		f = os.Stdout
 		// Turn off verbose messages, so that they don't end up in the SVG output
 		pi.verbose = false
 	} else {
-		f, err = os.Create(filename)
+		f, err = CreateTemp(filename, 0)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
+		defer func() {
+			err = CommitTemp(f, filename, 0, err)
+		}()
 	}
 
-	// Write the generated SVG image to file or to stdout
-	if _, err = f.Write(pi.Bytes()); err != nil {
+	if !strings.HasSuffix(strings.ToLower(filename), ".svgz") {
+		return pi.WriteSVGTo(f)
+	}
+	zw, err := gzip.NewWriterLevel(f, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if err := pi.WriteSVGTo(zw); err != nil {
 		return err
 	}
-	return nil
+	return zw.Close()
 }
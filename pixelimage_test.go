@@ -0,0 +1,73 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// frames returns n images of the given size, with different colors, like the frames of an animation
+func frames(n, w, h int) []image.Image {
+	imgs := make([]image.Image, n)
+	for i := range imgs {
+		imgs[i] = uniformImage(w, h, color.NRGBA{uint8(i * 16), 0x80, 0xff, 0xff})
+	}
+	return imgs
+}
+
+func TestResetAllocations(t *testing.T) {
+	imgs := frames(4, 32, 32)
+	pi := NewPixelImage(imgs[0], false)
+	i := 0
+	reset := testing.AllocsPerRun(10, func() {
+		pi.Reset(imgs[i%len(imgs)])
+		i++
+	})
+	fresh := testing.AllocsPerRun(10, func() {
+		NewPixelImage(imgs[i%len(imgs)], false)
+		i++
+	})
+	if reset >= fresh {
+		t.Errorf("expected Reset to allocate less than NewPixelImage, got %v and %v allocations", reset, fresh)
+	}
+}
+
+func TestResetKeepsOptions(t *testing.T) {
+	pi := NewPixelImage(uniformImage(4, 4, color.NRGBA{0xff, 0, 0, 0xff}), false)
+	pi.SetNamedColors(false)
+	pi.Cover(false, false)
+	// #000080 is navy, which is written by name unless named colors are turned off
+	pi.Reset(uniformImage(4, 4, color.NRGBA{0, 0, 0x80, 0xff}))
+	if pi.RectangleCount() != 0 {
+		t.Errorf("expected no rectangles after Reset, got %d", pi.RectangleCount())
+	}
+	pi.Cover(false, false)
+	svg := pi.Bytes()
+	if !bytes.Contains(svg, []byte(`fill="#000080"`)) || bytes.Contains(svg, []byte("navy")) {
+		t.Errorf("expected the named colors to stay turned off after Reset, got:\n%s", svg)
+	}
+}
+
+func BenchmarkConvertFrames(b *testing.B) {
+	imgs := frames(16, 64, 64)
+	b.Run("NewPixelImage", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, img := range imgs {
+				pi := NewPixelImage(img, false)
+				pi.Cover(false, false)
+			}
+		}
+	})
+	b.Run("Reset", func(b *testing.B) {
+		b.ReportAllocs()
+		pi := NewPixelImage(imgs[0], false)
+		for i := 0; i < b.N; i++ {
+			for _, img := range imgs {
+				pi.Reset(img)
+				pi.Cover(false, false)
+			}
+		}
+	})
+}
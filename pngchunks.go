@@ -0,0 +1,70 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// pngSignature is the 8 byte signature that all PNG files start with
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngChunk is the type and data of a chunk in PNG data, together with
+// the position right after the end of the chunk
+type pngChunk struct {
+	name string
+	data []byte
+	end  int
+}
+
+// pngChunks returns the chunks in the given PNG data, up to and including the IEND chunk.
+// The chunk data is not parsed. Parsing stops if the data is not valid.
+func pngChunks(data []byte) []pngChunk {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil
+	}
+	var chunks []pngChunk
+	pos := len(pngSignature)
+	for pos+8 <= len(data) {
+		length := binary.BigEndian.Uint32(data[pos:])
+		if uint64(length) > uint64(len(data)) {
+			break
+		}
+		// length + chunk type + chunk data + CRC
+		end := pos + 12 + int(length)
+		if end > len(data) {
+			break
+		}
+		name := string(data[pos+4 : pos+8])
+		chunks = append(chunks, pngChunk{name, data[pos+8 : end-4], end})
+		if name == "IEND" {
+			break
+		}
+		pos = end
+	}
+	return chunks
+}
+
+// trimAfterIEND returns the PNG data up to and including the IEND chunk,
+// together with the number of trailing bytes that were found after it.
+// If the data can not be parsed as PNG chunks, it is returned as it is.
+func trimAfterIEND(data []byte) ([]byte, int) {
+	chunks := pngChunks(data)
+	if len(chunks) == 0 || chunks[len(chunks)-1].name != "IEND" {
+		return data, 0
+	}
+	end := chunks[len(chunks)-1].end
+	return data[:end], len(data) - end
+}
+
+// colorSpaceChunks returns the names of the chunks in the given PNG data
+// that describes a color profile or color space, like "iCCP" or "sRGB".
+func colorSpaceChunks(data []byte) []string {
+	var names []string
+	for _, chunk := range pngChunks(data) {
+		switch chunk.name {
+		case "iCCP", "sRGB", "gAMA", "cHRM":
+			names = append(names, chunk.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package png2svg
+
+import (
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// samePixels checks if the two images have the same size and the same colors
+func samePixels(a, b image.Image) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestReadPNGTrailingGarbage(t *testing.T) {
+	want, err := ReadPNG(filepath.Join("testdata", "jumpline16.png"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filename string
+		trailing int
+	}{
+		{"jumpline16.png", 0},
+		{"trailing_garbage.png", 36},
+	}
+	for _, test := range tests {
+		filename := filepath.Join("testdata", test.filename)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trimmed, trailing := trimAfterIEND(data)
+		if trailing != test.trailing {
+			t.Errorf("%s: expected %d trailing bytes, got %d", test.filename, test.trailing, trailing)
+		}
+		if len(trimmed)+trailing != len(data) {
+			t.Errorf("%s: expected %d bytes to be kept, got %d", test.filename, len(data)-trailing, len(trimmed))
+		}
+		img, err := ReadPNG(filename, false)
+		if err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+		if !samePixels(img, want) {
+			t.Errorf("%s: the pixels differ from jumpline16.png", test.filename)
+		}
+	}
+}
+
+func TestColorSpaceChunks(t *testing.T) {
+	want, err := ReadPNG(filepath.Join("testdata", "jumpline16.png"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		filename string
+		names    string
+	}{
+		{"jumpline16.png", ""},
+		{"trailing_garbage.png", ""},
+		{"srgb.png", "sRGB"},
+	}
+	for _, test := range tests {
+		filename := filepath.Join("testdata", test.filename)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := strings.Join(colorSpaceChunks(data), ","); names != test.names {
+			t.Errorf("%s: expected the color space chunks %q, got %q", test.filename, test.names, names)
+		}
+		// The color space chunks are only reported, not applied
+		img, err := ReadPNG(filename, false)
+		if err != nil {
+			t.Fatalf("%s: %v", test.filename, err)
+		}
+		if !samePixels(img, want) {
+			t.Errorf("%s: the pixels differ from jumpline16.png", test.filename)
+		}
+	}
+}
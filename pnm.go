@@ -0,0 +1,180 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+)
+
+// isPNM checks if the given data starts with the magic number of a
+// Netpbm image (PBM, PGM or PPM), in either the plain or the raw format
+func isPNM(data []byte) bool {
+	return len(data) >= 3 && data[0] == 'P' && data[1] >= '1' && data[1] <= '6' && isPNMSpace(data[2])
+}
+
+// isPNMSpace checks if the given byte is whitespace, according to the Netpbm formats
+func isPNMSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f'
+}
+
+// pnmReader reads the whitespace separated numbers of a Netpbm image
+type pnmReader struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next number, while skipping whitespace and comments
+func (r *pnmReader) next() (int, error) {
+	for r.pos < len(r.data) {
+		if r.data[r.pos] == '#' {
+			for r.pos < len(r.data) && r.data[r.pos] != '\n' && r.data[r.pos] != '\r' {
+				r.pos++
+			}
+			continue
+		}
+		if !isPNMSpace(r.data[r.pos]) {
+			break
+		}
+		r.pos++
+	}
+	start := r.pos
+	for r.pos < len(r.data) && r.data[r.pos] >= '0' && r.data[r.pos] <= '9' {
+		r.pos++
+	}
+	if start == r.pos {
+		return 0, errors.New("the PNM image is truncated or contains an invalid number")
+	}
+	return strconv.Atoi(string(r.data[start:r.pos]))
+}
+
+// bit returns the next "0" or "1" of a plain PBM image, which may be written without whitespace
+func (r *pnmReader) bit() (int, error) {
+	for r.pos < len(r.data) && (isPNMSpace(r.data[r.pos]) || r.data[r.pos] == '#') {
+		if r.data[r.pos] == '#' {
+			for r.pos < len(r.data) && r.data[r.pos] != '\n' && r.data[r.pos] != '\r' {
+				r.pos++
+			}
+			continue
+		}
+		r.pos++
+	}
+	if r.pos >= len(r.data) || (r.data[r.pos] != '0' && r.data[r.pos] != '1') {
+		return 0, errors.New("the PBM image is truncated or contains an invalid bit")
+	}
+	r.pos++
+	return int(r.data[r.pos-1] - '0'), nil
+}
+
+// decodePNM decodes the given PBM (P1 and P4), PGM (P2 and P5) or PPM (P3 and P6) image data
+func decodePNM(data []byte) (image.Image, error) {
+	if !isPNM(data) {
+		return nil, errors.New("not a PNM image")
+	}
+	kind := data[1]
+	r := &pnmReader{data: data, pos: 2}
+	w, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	h, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if w <= 0 || h <= 0 || w > 1<<15 || h > 1<<15 {
+		return nil, errors.New("invalid PNM image size")
+	}
+	maxval := 1
+	if kind != '1' && kind != '4' {
+		if maxval, err = r.next(); err != nil {
+			return nil, err
+		}
+		if maxval <= 0 || maxval > 65535 {
+			return nil, fmt.Errorf("invalid PNM maximum value: %d", maxval)
+		}
+	}
+	// The raw formats have a single whitespace character between the header and the pixels
+	r.pos++
+
+	var (
+		channels = 1
+		raw      = kind >= '4'
+		size     = 1
+	)
+	if kind == '3' || kind == '6' {
+		channels = 3
+	}
+	if maxval > 255 {
+		size = 2
+	}
+
+	img := image.NewNRGBA64(image.Rect(0, 0, w, h))
+
+	// sample returns the next sample, scaled to 16 bits
+	sample := func() (uint16, error) {
+		var v int
+		switch {
+		case !raw:
+			n, err := r.next()
+			if err != nil {
+				return 0, err
+			}
+			v = n
+		case r.pos+size > len(data):
+			return 0, errors.New("the PNM image is truncated")
+		case size == 2:
+			v = int(data[r.pos])<<8 | int(data[r.pos+1])
+			r.pos += 2
+		default:
+			v = int(data[r.pos])
+			r.pos++
+		}
+		if v > maxval {
+			v = maxval
+		}
+		return uint16(v * 0xffff / maxval), nil
+	}
+
+	for y := 0; y < h; y++ {
+		if kind == '4' {
+			// Each row of a raw PBM image is padded to a whole byte
+			rowBytes := (w + 7) / 8
+			if r.pos+rowBytes > len(data) {
+				return nil, errors.New("the PBM image is truncated")
+			}
+			for x := 0; x < w; x++ {
+				var v uint16 = 0xffff
+				if data[r.pos+x/8]&(0x80>>uint(x%8)) != 0 {
+					// 1 is black
+					v = 0
+				}
+				img.SetNRGBA64(x, y, color.NRGBA64{v, v, v, 0xffff})
+			}
+			r.pos += rowBytes
+			continue
+		}
+		for x := 0; x < w; x++ {
+			if kind == '1' {
+				b, err := r.bit()
+				if err != nil {
+					return nil, err
+				}
+				v := uint16((1 - b) * 0xffff)
+				img.SetNRGBA64(x, y, color.NRGBA64{v, v, v, 0xffff})
+				continue
+			}
+			var c [3]uint16
+			for i := 0; i < channels; i++ {
+				if c[i], err = sample(); err != nil {
+					return nil, err
+				}
+			}
+			if channels == 1 {
+				c[1], c[2] = c[0], c[0]
+			}
+			img.SetNRGBA64(x, y, color.NRGBA64{c[0], c[1], c[2], 0xffff})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,18 @@
+package png2svg
+
+// Posterize reduces each of r, g and b to the given number of levels, evenly spread from 0 to 255,
+// so that antialiasing and noise collapse into flat regions of one color. This is simpler than
+// reducing the image to a number of colors. The alpha values are left as they are.
+// It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) Posterize(levels int) {
+	if levels < 2 || levels >= 256 {
+		return
+	}
+	step := 255.0 / float64(levels-1)
+	reduce := func(v int) int {
+		return int(float64(int(float64(v)/step+0.5)) * step)
+	}
+	for _, p := range pi.pixels {
+		p.r, p.g, p.b = reduce(p.r), reduce(p.g), reduce(p.b)
+	}
+}
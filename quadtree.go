@@ -0,0 +1,88 @@
+package png2svg
+
+import "fmt"
+
+// The kinds of squares that CoverQuadtree can find
+const (
+	quadOutside = iota // the square is outside of the image
+	quadCovered        // all pixels in the square are already covered
+	quadUniform        // all pixels in the square are uncovered and have the same color
+	quadMixed          // the square has been split into smaller squares
+)
+
+// CoverQuadtree will cover all pixels that are not yet covered by an SVG element, by recursively
+// splitting the image into four squares, until each square has only one color. The squares have
+// sizes that are powers of two, and they are placed in the order of the quadtree: top left,
+// top right, bottom left and then bottom right. Squares along the right and bottom edges
+// are cut off where the image ends.
+func (pi *PixelImage) CoverQuadtree() {
+	size := 1
+	for size < pi.w || size < pi.h {
+		size *= 2
+	}
+	if kind, i := pi.coverQuad(0, 0, size); kind == quadUniform {
+		pi.coverSquare(0, 0, size, i)
+	}
+	if pi.verbose {
+		fmt.Printf("Covered the pixels with %d squares.\n", len(pi.rects))
+	}
+}
+
+// coverQuad finds the kind of the square with the given position and size. Uniform squares are only
+// covered when they are merged into a larger square, or when a square next to them has another color.
+// For uniform squares, the index of the top left pixel that is in the image is also returned.
+func (pi *PixelImage) coverQuad(x, y, size int) (kind, i int) {
+	if x >= pi.w || y >= pi.h {
+		return quadOutside, 0
+	}
+	if size == 1 {
+		i = y*pi.w + x
+		if pi.pixels[i].covered {
+			return quadCovered, i
+		}
+		return quadUniform, i
+	}
+	half := size / 2
+	var (
+		kinds   [4]int
+		indices [4]int
+	)
+	kind, i = quadOutside, 0
+	for q := range kinds {
+		kinds[q], indices[q] = pi.coverQuad(x+q%2*half, y+q/2*half, half)
+		switch {
+		case kinds[q] == quadOutside:
+		case kind == quadOutside:
+			kind, i = kinds[q], indices[q]
+		case kind != kinds[q] || kind == quadMixed:
+			kind = quadMixed
+		case kind == quadUniform:
+			p, o := pi.pixels[i], pi.pixels[indices[q]]
+			if p.r != o.r || p.g != o.g || p.b != o.b || p.a != o.a {
+				kind = quadMixed
+			}
+		}
+	}
+	if kind == quadMixed {
+		for q := range kinds {
+			if kinds[q] == quadUniform {
+				pi.coverSquare(x+q%2*half, y+q/2*half, half, indices[q])
+			}
+		}
+	}
+	return kind, i
+}
+
+// coverSquare covers the square with the given position and size with the color of the pixel
+// at index i, where the square is cut off at the edges of the image
+func (pi *PixelImage) coverSquare(x, y, size, i int) {
+	w, h := size, size
+	if x+w > pi.w {
+		w = pi.w - x
+	}
+	if y+h > pi.h {
+		h = pi.h - y
+	}
+	p := pi.pixels[i]
+	pi.CoverBox(&Box{x, y, w, h, p.r, p.g, p.b, p.a}, false, pi.colorOptimize)
+}
@@ -0,0 +1,203 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quantizer decides how the colors of an image are reduced, when a number of colors is given
+type Quantizer int
+
+const (
+	// MedianCutQuantizer splits the colors at the median of the channel with the largest range
+	MedianCutQuantizer Quantizer = iota
+	// OctreeQuantizer merges similar colors in a tree, which is faster and uses less memory for large images
+	OctreeQuantizer
+	// KMeansQuantizer improves the colors from median cut with k-means, which is slower, but gives the best colors
+	KMeansQuantizer
+)
+
+// ParseQuantizer returns the Quantizer for the given name,
+// which can be "mediancut", "octree" or "kmeans".
+func ParseQuantizer(name string) (Quantizer, error) {
+	switch strings.ToLower(name) {
+	case "mediancut", "median":
+		return MedianCutQuantizer, nil
+	case "octree":
+		return OctreeQuantizer, nil
+	case "kmeans", "k-means":
+		return KMeansQuantizer, nil
+	}
+	return MedianCutQuantizer, errors.New("unknown quantizer: " + name + " (must be mediancut, octree or kmeans)")
+}
+
+// String returns the name of the Quantizer
+func (q Quantizer) String() string {
+	switch q {
+	case OctreeQuantizer:
+		return "octree"
+	case KMeansQuantizer:
+		return "kmeans"
+	}
+	return "mediancut"
+}
+
+// colorCount is a color, as r, g, b and a, together with the number of pixels that have it
+type colorCount struct {
+	c     [4]int
+	count int
+}
+
+// colorCounts returns the colors of the pixels that are not transparent, and how many pixels
+// have each color. The colors are sorted, so that the same image always gives the same result.
+func (pi *PixelImage) colorCounts() []colorCount {
+	counts := make(map[[4]int]int)
+	for _, p := range pi.pixels {
+		if p.a > 0 {
+			counts[[4]int{p.r, p.g, p.b, p.a}]++
+		}
+	}
+	colors := make([]colorCount, 0, len(counts))
+	for c, count := range counts {
+		colors = append(colors, colorCount{c, count})
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return colors
+}
+
+// averageColor returns the average of the given colors, weighted by how many pixels have each color
+func averageColor(colors []colorCount) [4]int {
+	var sum [4]int
+	total := 0
+	for _, cc := range colors {
+		for k := range sum {
+			sum[k] += cc.c[k] * cc.count
+		}
+		total += cc.count
+	}
+	for k := range sum {
+		sum[k] = (sum[k] + total/2) / total
+	}
+	return sum
+}
+
+// widestChannel returns the channel (0 for red, 1 for green, 2 for blue and 3 for alpha)
+// where the given colors have the largest range, together with the range
+func widestChannel(colors []colorCount) (channel, width int) {
+	for k := 0; k < 4; k++ {
+		low, high := 255, 0
+		for _, cc := range colors {
+			if cc.c[k] < low {
+				low = cc.c[k]
+			}
+			if cc.c[k] > high {
+				high = cc.c[k]
+			}
+		}
+		if high-low > width {
+			channel, width = k, high-low
+		}
+	}
+	return channel, width
+}
+
+// MedianCut reduces the image to at most n colors, with median cut quantization. The colors are
+// split into two boxes at the median of the channel with the largest range, again and again, until
+// there are n boxes, and all colors in a box are replaced by their average. Transparent pixels are
+// left as they are. It must be called before the pixels are covered with rectangles.
+func (pi *PixelImage) MedianCut(n int) {
+	if n < 1 {
+		return
+	}
+	colors := pi.colorCounts()
+	if len(colors) <= n {
+		return
+	}
+	pi.replaceColors(medianCut(colors, n))
+}
+
+// medianCut splits the given colors into at most n boxes, with median cut
+func medianCut(colors []colorCount, n int) [][]colorCount {
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box with the largest range
+		best, bestChannel, bestWidth := -1, 0, 0
+		for i, box := range boxes {
+			if channel, width := widestChannel(box); width > bestWidth {
+				best, bestChannel, bestWidth = i, channel, width
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool {
+			return box[i].c[bestChannel] < box[j].c[bestChannel]
+		})
+		// Find the median pixel, but keep at least one color on each side
+		total := 0
+		for _, cc := range box {
+			total += cc.count
+		}
+		split, sum := 1, box[0].count
+		for split < len(box)-1 && sum < total/2 {
+			sum += box[split].count
+			split++
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	return boxes
+}
+
+// replaceColors gives all pixels that have one of the colors in a box the average color of the box
+func (pi *PixelImage) replaceColors(boxes [][]colorCount) {
+	replacements := make(map[[4]int][4]int)
+	for _, box := range boxes {
+		average := averageColor(box)
+		for _, cc := range box {
+			replacements[cc.c] = average
+		}
+	}
+	for _, p := range pi.pixels {
+		if c, ok := replacements[[4]int{p.r, p.g, p.b, p.a}]; ok {
+			p.r, p.g, p.b, p.a = c[0], c[1], c[2], c[3]
+		}
+	}
+}
+
+// ColorFrequency is a color, as "#rrggbb", or "#rrggbbaa" if it is semi-transparent,
+// together with the number of pixels that have it
+type ColorFrequency struct {
+	Color string
+	Count int
+}
+
+// Histogram returns the colors of the pixels that are not transparent, and how many pixels have
+// each color, with the most frequent color first. This can help with choosing the number of colors,
+// before the image is covered with rectangles. Colors that are just as frequent are sorted by value.
+func (pi *PixelImage) Histogram() []ColorFrequency {
+	colors := pi.colorCounts()
+	sort.SliceStable(colors, func(i, j int) bool {
+		return colors[i].count > colors[j].count
+	})
+	histogram := make([]ColorFrequency, len(colors))
+	for i, cc := range colors {
+		color := string(hexColorBytes(cc.c[0], cc.c[1], cc.c[2]))
+		if cc.c[3] < 255 {
+			color += fmt.Sprintf("%02x", cc.c[3])
+		}
+		histogram[i] = ColorFrequency{color, cc.count}
+	}
+	return histogram
+}
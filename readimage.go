@@ -0,0 +1,109 @@
+package png2svg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// ReadImage tries to read the given PNG, JPEG, GIF, BMP, TIFF, ICO, farbfeld or PNM image filename and returns an image.Image
+// and an error. The image format is detected from the contents of the file.
+// For animated GIF images, only the first frame is used, and for animated PNG
+// images, only the default image is used. See ReadFrames for reading all frames.
+// For ICO images, the largest image is used. See ReadICO for selecting another size.
+// If autoRotate is true, JPEG images are rotated and flipped according to their EXIF orientation.
+// If verbose is true, some basic information is printed to stdout.
+func ReadImage(filename string, autoRotate, verbose bool) (image.Image, error) {
+	if verbose {
+		fmt.Printf("Reading %s", filename)
+		defer fmt.Println()
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data, autoRotate, verbose)
+}
+
+// ReadImageFrom tries to read a PNG, JPEG, GIF, BMP, TIFF, ICO, farbfeld or PNM image from the given io.Reader,
+// like stdin or a HTTP response body, and returns an image.Image and an error. See ReadImage.
+func ReadImageFrom(r io.Reader, autoRotate, verbose bool) (image.Image, error) {
+	if verbose {
+		fmt.Print("Reading image")
+		defer fmt.Println()
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data, autoRotate, verbose)
+}
+
+// decodeImage decodes the given image data, where the image format is detected from the contents
+func decodeImage(data []byte, autoRotate, verbose bool) (image.Image, error) {
+	var (
+		img image.Image
+		err error
+	)
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		img, err = decodePNG(data, verbose)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err == nil && autoRotate {
+			if orientation := ExifOrientation(data); orientation != 1 {
+				if verbose {
+					fmt.Printf(" (applying EXIF orientation %d)", orientation)
+				}
+				img = Orient(img, orientation)
+			}
+		}
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		img, err = decodeGIF(data)
+	case bytes.HasPrefix(data, []byte("BM")):
+		img, err = bmp.Decode(bytes.NewReader(data))
+	case bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")):
+		img, err = tiff.Decode(bytes.NewReader(data))
+	case isICO(data):
+		img, err = decodeICO(data, 0)
+	case bytes.HasPrefix(data, farbfeldMagic):
+		img, err = decodeFarbfeld(data)
+	case isPNM(data):
+		img, err = decodePNM(data)
+	default:
+		err = errors.New("not a PNG, JPEG, GIF, BMP, TIFF, ICO, farbfeld or PNM image")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf(" (%dx%d)", img.Bounds().Max.X-img.Bounds().Min.X, img.Bounds().Max.Y-img.Bounds().Min.Y)
+	}
+	return img, nil
+}
+
+// decodeGIF decodes the first frame of the given GIF data.
+// The frame is drawn on a canvas with the full size of the GIF image,
+// since frames may be smaller than the image and placed with an offset.
+func decodeGIF(data []byte) (image.Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, errors.New("the GIF image has no frames")
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	frame := g.Image[0]
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas, nil
+}
@@ -0,0 +1,75 @@
+package png2svg
+
+import (
+	"image"
+	"math"
+)
+
+// simplifyLoops simplifies each of the given loops of corners with simplifyLoop.
+// If the tolerance is 0, the loops are returned as they are.
+func simplifyLoops(loops [][]image.Point, tolerance float64) [][]image.Point {
+	if tolerance <= 0 {
+		return loops
+	}
+	simplified := make([][]image.Point, len(loops))
+	for i, loop := range loops {
+		simplified[i] = simplifyLoop(loop, tolerance)
+	}
+	return simplified
+}
+
+// simplifyLoop removes corners from a closed loop with the Ramer-Douglas-Peucker algorithm,
+// so that no removed corner is further away from the simplified outline than the given tolerance,
+// in pixels. The loop is split in two at the corner that is furthest from the first one.
+// Loops that would become too small to have an area are kept as they are.
+func simplifyLoop(loop []image.Point, tolerance float64) []image.Point {
+	if len(loop) < 4 {
+		return loop
+	}
+	far, farDistance := 0, 0.0
+	for i, p := range loop {
+		if d := segmentDistance(p, loop[0], loop[0]); d > farDistance {
+			far, farDistance = i, d
+		}
+	}
+	closed := append(loop[far:len(loop):len(loop)], loop[0])
+	simplified := append(rdp(loop[:far+1], tolerance), rdp(closed, tolerance)[1:]...)
+	// The first corner is also the last one
+	simplified = simplified[:len(simplified)-1]
+	if len(simplified) < 3 {
+		return loop
+	}
+	return simplified
+}
+
+// rdp simplifies the given open polyline with the Ramer-Douglas-Peucker algorithm,
+// keeping the first and the last point
+func rdp(points []image.Point, tolerance float64) []image.Point {
+	if len(points) < 3 {
+		return points
+	}
+	first, last := points[0], points[len(points)-1]
+	far, farDistance := 0, 0.0
+	for i := 1; i < len(points)-1; i++ {
+		if d := segmentDistance(points[i], first, last); d > farDistance {
+			far, farDistance = i, d
+		}
+	}
+	if farDistance <= tolerance {
+		return []image.Point{first, last}
+	}
+	left := rdp(points[:far+1], tolerance)
+	return append(left[:len(left):len(left)], rdp(points[far:], tolerance)[1:]...)
+}
+
+// segmentDistance returns the distance from p to the line segment from a to b
+func segmentDistance(p, a, b image.Point) float64 {
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	px, py := float64(p.X-a.X), float64(p.Y-a.Y)
+	if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+		// Project p onto the segment
+		t := math.Max(0, math.Min(1, (px*dx+py*dy)/lengthSquared))
+		px, py = px-t*dx, py-t*dy
+	}
+	return math.Hypot(px, py)
+}
@@ -0,0 +1,68 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"math"
+)
+
+// defaultCornerThreshold is the default turn, in degrees, where a smoothed outline keeps a sharp corner
+const defaultCornerThreshold = 80
+
+// turnAngle returns how much the direction changes at b, in degrees from 0 to 180,
+// when going from a to b and then on to c
+func turnAngle(a, b, c image.Point) float64 {
+	inx, iny := float64(b.X-a.X), float64(b.Y-a.Y)
+	outx, outy := float64(c.X-b.X), float64(c.Y-b.Y)
+	return math.Abs(math.Atan2(inx*outy-iny*outx, inx*outx+iny*outy)) * 180 / math.Pi
+}
+
+// smoothLoopData returns the path data that draws the given loops of corners with curves, like potrace.
+// Each side is split in the middle, and the middles are joined by quadratic Bézier curves,
+// with the corner in between as the control point. Corners where the outline turns by at least
+// the threshold, in degrees, are kept sharp.
+func smoothLoopData(loops [][]image.Point, threshold float64, c coordinates) []byte {
+	var buf bytes.Buffer
+	// writePoint writes the given command, if it is not empty, and the given absolute position
+	writePoint := func(command string, x, y float64) {
+		buf.WriteString(command)
+		buf.WriteString(c.format(c.offsetX + x*c.scale))
+		if s := c.format(c.offsetY + y*c.scale); s[0] == '-' {
+			buf.WriteString(s)
+		} else {
+			buf.WriteByte(' ')
+			buf.WriteString(s)
+		}
+	}
+	for _, loop := range loops {
+		n := len(loop)
+		sharp := make([]bool, n)
+		for i := range loop {
+			sharp[i] = turnAngle(loop[(i+n-1)%n], loop[i], loop[(i+1)%n]) >= threshold
+		}
+		middle := func(i int) (float64, float64) {
+			a, b := loop[i%n], loop[(i+1)%n]
+			return float64(a.X+b.X) / 2, float64(a.Y+b.Y) / 2
+		}
+
+		// Start in the middle of the first side, and go around until it is reached again
+		mx, my := middle(0)
+		writePoint("M", mx, my)
+		for i := 1; i <= n; i++ {
+			p := loop[i%n]
+			mx, my = middle(i)
+			if sharp[i%n] {
+				writePoint("L", float64(p.X), float64(p.Y))
+				if !sharp[(i+1)%n] && i < n {
+					// The next curve starts in the middle of this side
+					writePoint("L", mx, my)
+				}
+				continue
+			}
+			writePoint("Q", float64(p.X), float64(p.Y))
+			writePoint(" ", mx, my)
+		}
+		buf.WriteByte('z')
+	}
+	return buf.Bytes()
+}
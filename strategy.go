@@ -0,0 +1,80 @@
+package png2svg
+
+import (
+	"errors"
+	"strings"
+)
+
+// Strategy is a way of covering the image with rectangles.
+// The strategies that expand rectangles have the same values as the corresponding ExpandOrder.
+type Strategy int
+
+const (
+	// RightFirstStrategy expands rectangles from the top left, to the right first
+	RightFirstStrategy = Strategy(RightFirst)
+	// DownFirstStrategy expands rectangles from the top left, downwards first
+	DownFirstStrategy = Strategy(DownFirst)
+	// BalancedStrategy expands rectangles from the top left, along the shortest side first
+	BalancedStrategy = Strategy(Balanced)
+	// RunsStrategy covers the image with horizontal runs of the same color
+	RunsStrategy Strategy = iota
+	// OptimalStrategy partitions each region into the smallest possible number of rectangles that do not overlap
+	OptimalStrategy
+	// QuadtreeStrategy covers the image with squares, by recursively splitting it
+	QuadtreeStrategy
+	// LargestFirstStrategy places the rectangle that covers the most pixels first, again and again
+	LargestFirstStrategy
+	// PixelsStrategy covers the image with one rectangle per pixel
+	PixelsStrategy
+)
+
+// ParseStrategy returns the Strategy for the given name, which can be "right", "down", "balanced",
+// "rle", "optimal", "quadtree", "largest" or "pixels". These are the names that the -strategy flag takes.
+func ParseStrategy(name string) (Strategy, error) {
+	if order, err := ParseExpandOrder(name); err == nil {
+		return Strategy(order), nil
+	}
+	switch strings.ToLower(name) {
+	case "rle", "runs":
+		return RunsStrategy, nil
+	case "optimal":
+		return OptimalStrategy, nil
+	case "quadtree":
+		return QuadtreeStrategy, nil
+	case "largest":
+		return LargestFirstStrategy, nil
+	case "pixels":
+		return PixelsStrategy, nil
+	}
+	return RightFirstStrategy, errors.New("unknown strategy: " + name + " (must be right, down, balanced, rle, optimal, quadtree, largest or pixels)")
+}
+
+// String returns the name of the Strategy
+func (s Strategy) String() string {
+	if order, ok := s.ExpandOrder(); ok {
+		return order.String()
+	}
+	switch s {
+	case RunsStrategy:
+		return "rle"
+	case OptimalStrategy:
+		return "optimal"
+	case QuadtreeStrategy:
+		return "quadtree"
+	case LargestFirstStrategy:
+		return "largest"
+	case PixelsStrategy:
+		return "pixels"
+	}
+	return "right"
+}
+
+// ExpandOrder returns the ExpandOrder of the strategies that expand rectangles,
+// and false for the other strategies
+func (s Strategy) ExpandOrder() (ExpandOrder, bool) {
+	switch s {
+	case RightFirstStrategy, DownFirstStrategy, BalancedStrategy:
+		return ExpandOrder(s), true
+	}
+	return RightFirst, false
+}
@@ -0,0 +1,54 @@
+package png2svg
+
+import "testing"
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		check    func(o *Options) bool
+	}{
+		{"right", RightFirstStrategy, func(o *Options) bool { return o.ExpandOrder == RightFirst }},
+		{"down", DownFirstStrategy, func(o *Options) bool { return o.ExpandOrder == DownFirst }},
+		{"balanced", BalancedStrategy, func(o *Options) bool { return o.ExpandOrder == Balanced }},
+		{"rle", RunsStrategy, func(o *Options) bool { return o.Runs }},
+		{"optimal", OptimalStrategy, func(o *Options) bool { return o.Optimal }},
+		{"quadtree", QuadtreeStrategy, func(o *Options) bool { return o.Quadtree }},
+		{"largest", LargestFirstStrategy, func(o *Options) bool { return o.LargestFirst }},
+		{"pixels", PixelsStrategy, func(o *Options) bool { return o.SinglePixelRectangles }},
+	}
+	for _, test := range tests {
+		strategy, err := ParseStrategy(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strategy != test.strategy {
+			t.Errorf("%s: expected %d, got %d", test.name, test.strategy, strategy)
+		}
+		if strategy.String() != test.name {
+			t.Errorf("%s: expected the name to be kept, got %s", test.name, strategy)
+		}
+		o := NewOptions()
+		if test.name == "right" {
+			// Start from another expand order, to see that it is changed
+			o.ExpandOrder = Balanced
+		}
+		WithStrategy(strategy)(o)
+		if !test.check(o) {
+			t.Errorf("%s: the options were not set by WithStrategy", test.name)
+		}
+	}
+	// The expand orders and the expanding strategies have the same names
+	for _, order := range expandOrders {
+		strategy, err := ParseStrategy(order.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := strategy.ExpandOrder(); !ok || got != order {
+			t.Errorf("expected the expand order %s, got %s", order, got)
+		}
+	}
+	if _, err := ParseStrategy("spiral"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
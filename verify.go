@@ -0,0 +1,44 @@
+package png2svg
+
+import "image"
+
+// Verify draws the rectangles that has been placed onto an empty canvas, in the order they were placed,
+// and compares the fill colors with the colors of the pixels in the image. The positions of the pixels that
+// differ are returned, in row order. Pixels that are not covered by any rectangle also differ.
+// Pixels that are covered by a shortened color, like #fff, are compared with their shortened colors.
+// The alpha values must also be the same, and fully transparent pixels should not be covered.
+func (pi *PixelImage) Verify() []image.Point {
+	canvas := make([]*Rect, pi.w*pi.h)
+	for i := range pi.rects {
+		r := &pi.rects[i]
+		for y := r.Y; y < r.Y+r.H && y < pi.h; y++ {
+			for x := r.X; x < r.X+r.W && x < pi.w; x++ {
+				canvas[y*pi.w+x] = r
+			}
+		}
+	}
+	var diffs []image.Point
+	for i, p := range pi.pixels {
+		r := canvas[i]
+		same := false
+		switch {
+		case p.a == 0:
+			// Fully transparent pixels are not drawn
+			same = r == nil
+		case r == nil || r.Alpha != p.a:
+		default:
+			expected := string(hexColorBytes(p.r, p.g, p.b))
+			if len(r.Fill) == len("#rgb") || len(r.Fill) == len("#rgba") {
+				expected = shortColorString(p.r, p.g, p.b)
+			}
+			if p.a < 255 && pi.hexAlpha {
+				expected = withAlpha(expected, p.a)
+			}
+			same = r.Fill == expected
+		}
+		if !same {
+			diffs = append(diffs, image.Point{X: i % pi.w, Y: i / pi.w})
+		}
+	}
+	return diffs
+}